@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"path"
+	"time"
 
 	"github.com/spf13/pflag"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -14,13 +15,19 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/clientcmd"
 
+	"github.com/flomesh-io/xnet/pkg/constants"
 	"github.com/flomesh-io/xnet/pkg/k8s"
 	"github.com/flomesh-io/xnet/pkg/k8s/informers"
 	"github.com/flomesh-io/xnet/pkg/logger"
 	"github.com/flomesh-io/xnet/pkg/messaging"
 	"github.com/flomesh-io/xnet/pkg/signals"
 	"github.com/flomesh-io/xnet/pkg/version"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/load"
 	"github.com/flomesh-io/xnet/pkg/xnet/cni/controller"
+	"github.com/flomesh-io/xnet/pkg/xnet/e4lb"
+	"github.com/flomesh-io/xnet/pkg/xnet/health"
+	"github.com/flomesh-io/xnet/pkg/xnet/metrics"
 	"github.com/flomesh-io/xnet/pkg/xnet/volume"
 )
 
@@ -34,10 +41,21 @@ var (
 	enableE4lb     bool
 	enableE4lbIPv4 bool
 	enableE4lbIPv6 bool
+	e4lbVipPools   []string
+	e4lbLbClass    string
+
+	e4lbHealthCheckInterval int
+	e4lbHealthCheckTimeout  int
+	e4lbHealthCheckRise     int
+	e4lbHealthCheckFall     int
+	e4lbHealthCheckHTTPPath string
+	e4lbHealthCheckUDP      bool
 
 	upgradeProg   bool
 	uninstallProg bool
 
+	metricsAddr string
+
 	meshCfgIPv4Magic string
 	meshCfgIPv6Magic string
 	e4lbCfgIPv4Magic string
@@ -60,6 +78,12 @@ var (
 	nodePathSysFs   string
 	nodePathSysRun  string
 
+	natMapEntries     uint32
+	natConnMapEntries uint32
+	aclMapEntries     uint32
+	flowMapEntries    uint32
+	traceMapEntries   uint32
+
 	cniIPv4BridgeName string
 	cniIPv4BridgeMac  string
 	cniIPv6BridgeName string
@@ -81,10 +105,20 @@ func init() {
 	flags.BoolVar(&enableE4lb, "enable-e4lb", false, "Enable 4-layer load balance")
 	flags.BoolVar(&enableE4lbIPv4, "enable-e4lb-ipv4", true, "Enable 4-layer load balance with ipv4")
 	flags.BoolVar(&enableE4lbIPv6, "enable-e4lb-ipv6", true, "Enable 4-layer load balance with ipv6")
-
-	flags.BoolVar(&upgradeProg, "upgrade-prog", false, "Upgrade xnet prog")
+	flags.StringArrayVar(&e4lbVipPools, "e4lb-vip-pool", nil, "e4lb vip pool, e.g. default=192.168.1.100-192.168.1.200,10.0.0.0/28, the first one is the default pool")
+	flags.StringVar(&e4lbLbClass, "e4lb-load-balancer-class", constants.E4lbLoadBalancerClass, "loadBalancerClass of the LoadBalancer services served by e4lb")
+	flags.IntVar(&e4lbHealthCheckInterval, "e4lb-health-check-interval-seconds", 0, "e4lb endpoint health check interval seconds, disabled if 0")
+	flags.IntVar(&e4lbHealthCheckTimeout, "e4lb-health-check-timeout-seconds", 2, "e4lb endpoint health check timeout seconds")
+	flags.IntVar(&e4lbHealthCheckRise, "e4lb-health-check-rise", 2, "consecutive successful checks to mark an e4lb endpoint healthy")
+	flags.IntVar(&e4lbHealthCheckFall, "e4lb-health-check-fall", 3, "consecutive failed checks to mark an e4lb endpoint unhealthy")
+	flags.StringVar(&e4lbHealthCheckHTTPPath, "e4lb-health-check-http-path", "", "http path requested on tcp e4lb endpoints, e.g. /healthz, tcp connect only if empty")
+	flags.BoolVar(&e4lbHealthCheckUDP, "e4lb-health-check-udp", false, "check udp e4lb endpoints, unhealthy on icmp port unreachable")
+
+	flags.BoolVar(&upgradeProg, "upgrade-prog", false, "Upgrade xnet prog, keeping pinned maps")
 	flags.BoolVar(&uninstallProg, "uninstall-prog", false, "Uninstall xnet prog")
 
+	flags.StringVar(&metricsAddr, "metrics-addr", "", "Address to serve prometheus metrics, e.g. :9091, disabled if empty")
+
 	flags.StringVar(&meshCfgIPv4Magic, "mesh-cfg-ipv4-magic", "", "mesh ipv4 config magic")
 	flags.StringVar(&meshCfgIPv6Magic, "mesh-cfg-ipv6-magic", "", "mesh ipv6 config magic")
 	flags.StringVar(&e4lbCfgIPv4Magic, "e4lb-cfg-ipv4-magic", "", "e4lb ipv4 config magic")
@@ -107,6 +141,12 @@ func init() {
 	flags.StringVar(&nodePathSysFs, "node-path-sys-fs", "", "sys fs node path")
 	flags.StringVar(&nodePathSysRun, "node-path-sys-run", "", "sys run node path")
 
+	flags.Uint32Var(&natMapEntries, "nat-map-entries", 0, "max entries of nat, maglev and wrr maps, 0 keeps the compiled-in size")
+	flags.Uint32Var(&natConnMapEntries, "nat-conn-map-entries", 0, "max entries of nat conn count map, 0 keeps the compiled-in size")
+	flags.Uint32Var(&aclMapEntries, "acl-map-entries", 0, "max entries of acl map, 0 keeps the compiled-in size")
+	flags.Uint32Var(&flowMapEntries, "flow-map-entries", 0, "max entries of tcp/udp flow maps, 0 keeps the compiled-in size")
+	flags.Uint32Var(&traceMapEntries, "trace-map-entries", 0, "max entries of trace maps, 0 keeps the compiled-in size")
+
 	flags.StringVar(&cniIPv4BridgeName, "cni-ipv4-bridge-name", "", "cni ipv4 bridge name")
 	flags.StringVar(&cniIPv4BridgeMac, "cni-ipv4-bridge-mac", "", "cni ipv4 bridge mac")
 	flags.StringVar(&cniIPv6BridgeName, "cni-ipv6-bridge-name", "", "cni ipv6 bridge name")
@@ -149,6 +189,18 @@ func parseFlags() error {
 		}
 	}
 
+	load.SetMapEntries(bpf.FSM_MAP_NAME_NAT, natMapEntries)
+	load.SetMapEntries(bpf.FSM_MAP_NAME_MAGLEV, natMapEntries)
+	load.SetMapEntries(bpf.FSM_MAP_NAME_WRR, natMapEntries)
+	load.SetMapEntries(bpf.FSM_MAP_NAME_NAT_CONN, natConnMapEntries)
+	load.SetMapEntries(bpf.FSM_MAP_NAME_ACL, aclMapEntries)
+	load.SetMapEntries(bpf.FSM_MAP_NAME_TCP_FLOW, flowMapEntries)
+	load.SetMapEntries(bpf.FSM_MAP_NAME_UDP_FLOW, flowMapEntries)
+	load.SetMapEntries(bpf.FSM_MAP_NAME_TCP_OPT, flowMapEntries)
+	load.SetMapEntries(bpf.FSM_MAP_NAME_UDP_OPT, flowMapEntries)
+	load.SetMapEntries(bpf.FSM_MAP_NAME_TRACE_IP, traceMapEntries)
+	load.SetMapEntries(bpf.FSM_MAP_NAME_TRACE_PORT, traceMapEntries)
+
 	return nil
 }
 
@@ -162,9 +214,30 @@ func validateCLIParams() error {
 		return fmt.Errorf("please specify the FSM namespace using --fsm-namespace")
 	}
 
+	for _, e4lbVipPool := range e4lbVipPools {
+		if _, err := e4lb.ParseVipPool(e4lbVipPool); err != nil {
+			return err
+		}
+	}
+
+	if err := getE4lbHealthCheck().Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+func getE4lbHealthCheck() health.Config {
+	return health.Config{
+		Interval: time.Second * time.Duration(e4lbHealthCheckInterval),
+		Timeout:  time.Second * time.Duration(e4lbHealthCheckTimeout),
+		Rise:     e4lbHealthCheckRise,
+		Fall:     e4lbHealthCheckFall,
+		HTTPPath: e4lbHealthCheckHTTPPath,
+		UDP:      e4lbHealthCheckUDP,
+	}
+}
+
 func main() {
 	log.Info().Msgf("Starting fsm-xnet-switcher %s; %s; %s", version.Version, version.GitCommit, version.BuildDate)
 	if err := parseFlags(); err != nil {
@@ -189,6 +262,9 @@ func main() {
 	opts := []informers.InformerCollectionOption{
 		informers.WithKubeClient(kubeClient),
 	}
+	if enableE4lb && !uninstallProg {
+		opts = append(opts, informers.WithServiceInformers(kubeClient))
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	stop := signals.RegisterExitHandlers(cancel)
@@ -218,9 +294,15 @@ func main() {
 		cniBridges = append(cniBridges, cni6Br)
 	}
 
-	server := controller.NewServer(ctx, kubeController, msgBroker, stop,
+	vipPools := make([]*e4lb.VipPool, 0)
+	for _, e4lbVipPool := range e4lbVipPools {
+		vipPool, _ := e4lb.ParseVipPool(e4lbVipPool)
+		vipPools = append(vipPools, vipPool)
+	}
+
+	server := controller.NewServer(ctx, kubeClient, kubeController, msgBroker, fsmNamespace, stop,
 		enableE4lb, enableE4lbIPv4, enableE4lbIPv6, enableMesh,
-		upgradeProg, uninstallProg, cniBridges,
+		upgradeProg, uninstallProg, cniBridges, vipPools, e4lbLbClass, getE4lbHealthCheck(),
 		meshCfgIPv4Magic, meshCfgIPv6Magic, e4lbCfgIPv4Magic, e4lbCfgIPv6Magic,
 		meshFilterPortInbound, meshFilterPortOutbound,
 		flushTCPConnTrackCrontab, flushTCPConnTrackIdleSeconds, flushTCPConnTrackBatchSize,
@@ -229,6 +311,10 @@ func main() {
 		log.Fatal().Msg(err.Error())
 	}
 
+	if len(metricsAddr) > 0 && !uninstallProg {
+		go metrics.Serve(metricsAddr, stop)
+	}
+
 	<-stop
 
 	log.Info().Msgf("Stopping fsm-xnet-switcher %s; %s; %s", version.Version, version.GitCommit, version.BuildDate)
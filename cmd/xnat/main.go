@@ -25,6 +25,7 @@ func newRootCmd(args []string) *cobra.Command {
 		cli.NewConfigCmd(),
 		cli.NewNatCmd(),
 		cli.NewAclCmd(),
+		cli.NewRateLimitCmd(),
 		cli.NewTraceCmd(),
 		cli.NewFlowCmd(),
 		cli.NewOptCmd(),
@@ -32,6 +33,7 @@ func newRootCmd(args []string) *cobra.Command {
 		cli.NewConvCmd(),
 		cli.NewIFaceCmd(),
 		cli.NewArpCmd(),
+		cli.NewStatsCmd(),
 		cli.NewWaitCmd(),
 	)
 
@@ -4,15 +4,33 @@
 #define FSM_CNI_PASS_PROG_ID (0)
 #define FSM_CNI_DROP_PROG_ID (1)
 #define FSM_CNI_FLOW_PROG_ID (2)
-#define FSM_PROGS_MAP_ENTRIES (3)
+#define FSM_CNI_REJECT_PROG_ID (3)
+#define FSM_PROGS_MAP_ENTRIES (4)
 
 #define FSM_FLOW_MAP_ENTRIES (1024 * 1024)
 #define FSM_ACL_MAP_ENTRIES (4 * 1024)
+#define FSM_ACL_CIDR_MAP_ENTRIES (1024)
+#define FSM_ACL_RANGE_MAP_ENTRIES (1024)
 #define FSM_NAT_MAP_ENTRIES (64)
 #define FSM_NAT_MAX_ENDPOINTS (128)
+#define FSM_NAT_MAGLEV_SIZE (16381)
+#define FSM_NAT_WRR_SIZE (4096)
+#define FSM_NAT_AFFINITY_MAP_ENTRIES (64 * 1024)
+#define FSM_NAT_RANGE_MAP_ENTRIES (1024)
+#define FSM_NAT_CONN_MAP_ENTRIES (16 * 1024)
+
+#define FSM_RATE_LIMIT_MAP_ENTRIES (1024)
+#define FSM_RATE_BUCKET_MAP_ENTRIES (64 * 1024)
 
 #define FSM_TRACE_MAP_ENTRIES (16)
+#define FSM_TRACE_RINGBUF_SIZE (256 * 1024)
 
 #define FSM_IFACE_MAP_ENTRIES (128)
 
+#define FSM_STAT_MAP_ENTRIES (64)
+
+#define FSM_REJECT_TTL (64)
+#define FSM_REJECT_ICMP_INTERVAL (10 * 1000 * 1000)
+#define FSM_REJECT_ICMP_BURST (10)
+
 #endif
\ No newline at end of file
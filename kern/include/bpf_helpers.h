@@ -229,6 +229,328 @@ xpkt_csum_set_udp_dst_port(skb_t *skb, xpkt_t *pkt, __be16 xport)
     return 0;
 }
 
+INTERNAL(__u16)
+xpkt_csum_fold(__s64 csum)
+{
+    __u32 sum = (__u32)csum;
+
+    sum = (sum & 0xffff) + (sum >> 16);
+    sum = (sum & 0xffff) + (sum >> 16);
+    return (__u16)~sum;
+}
+
+INTERNAL(int)
+xpkt_reject_eth(skb_t *skb, xpkt_t *pkt)
+{
+    struct ethhdr eth;
+
+    XMAC_COPY(eth.h_dest, pkt->smac);
+    XMAC_COPY(eth.h_source, pkt->dmac);
+    eth.h_proto = pkt->l2_type;
+
+    return bpf_skb_store_bytes(skb, 0, &eth, sizeof(eth), 0);
+}
+
+INTERNAL(int)
+xpkt_reject_tcp4(skb_t *skb, xpkt_t *pkt)
+{
+    struct {
+        struct iphdr ip;
+        struct tcphdr tcp;
+    } __attribute__((packed)) rst;
+    struct {
+        __be32 saddr;
+        __be32 daddr;
+        __u8 zero;
+        __u8 proto;
+        __be16 len;
+    } __attribute__((packed)) ph;
+    __s64 csum;
+
+    if (pkt->l4_off != pkt->l3_off + sizeof(struct iphdr)) {
+        return -1;
+    }
+
+    memset(&rst, 0, sizeof(rst));
+    rst.ip.version = 4;
+    rst.ip.ihl = sizeof(struct iphdr) >> 2;
+    rst.ip.tot_len = htons(sizeof(rst));
+    rst.ip.ttl = FSM_REJECT_TTL;
+    rst.ip.protocol = IPPROTO_TCP;
+    rst.ip.saddr = pkt->flow.daddr4;
+    rst.ip.daddr = pkt->flow.saddr4;
+    csum = bpf_csum_diff(NULL, 0, (__be32 *)&rst.ip, sizeof(rst.ip), 0);
+    rst.ip.check = xpkt_csum_fold(csum);
+
+    rst.tcp.source = pkt->flow.dport;
+    rst.tcp.dest = pkt->flow.sport;
+    rst.tcp.ack_seq = htonl(ntohl(pkt->tcp_seq) + 1);
+    rst.tcp.doff = sizeof(struct tcphdr) >> 2;
+    rst.tcp.rst = 1;
+    rst.tcp.ack = 1;
+
+    ph.saddr = rst.ip.saddr;
+    ph.daddr = rst.ip.daddr;
+    ph.zero = 0;
+    ph.proto = IPPROTO_TCP;
+    ph.len = htons(sizeof(rst.tcp));
+    csum = bpf_csum_diff(NULL, 0, (__be32 *)&ph, sizeof(ph), 0);
+    csum = bpf_csum_diff(NULL, 0, (__be32 *)&rst.tcp, sizeof(rst.tcp), csum);
+    rst.tcp.check = xpkt_csum_fold(csum);
+
+    if (bpf_skb_change_tail(skb, pkt->l3_off + sizeof(rst), 0) < 0) {
+        return -1;
+    }
+    return bpf_skb_store_bytes(skb, pkt->l3_off, &rst, sizeof(rst), 0);
+}
+
+INTERNAL(int)
+xpkt_reject_tcp6(skb_t *skb, xpkt_t *pkt)
+{
+    struct {
+        struct ipv6hdr ip;
+        struct tcphdr tcp;
+    } __attribute__((packed)) rst;
+    struct {
+        __u32 saddr[IP_ALEN];
+        __u32 daddr[IP_ALEN];
+        __be32 len;
+        __u8 zero[3];
+        __u8 nexthdr;
+    } __attribute__((packed)) ph;
+    __s64 csum;
+
+    memset(&rst, 0, sizeof(rst));
+    rst.ip.version = 6;
+    rst.ip.payload_len = htons(sizeof(rst.tcp));
+    rst.ip.nexthdr = IPPROTO_TCP;
+    rst.ip.hop_limit = FSM_REJECT_TTL;
+    XADDR_COPY(&rst.ip.saddr, pkt->flow.daddr);
+    XADDR_COPY(&rst.ip.daddr, pkt->flow.saddr);
+
+    rst.tcp.source = pkt->flow.dport;
+    rst.tcp.dest = pkt->flow.sport;
+    rst.tcp.ack_seq = htonl(ntohl(pkt->tcp_seq) + 1);
+    rst.tcp.doff = sizeof(struct tcphdr) >> 2;
+    rst.tcp.rst = 1;
+    rst.tcp.ack = 1;
+
+    memset(&ph, 0, sizeof(ph));
+    XADDR_COPY(ph.saddr, pkt->flow.daddr);
+    XADDR_COPY(ph.daddr, pkt->flow.saddr);
+    ph.len = htonl(sizeof(rst.tcp));
+    ph.nexthdr = IPPROTO_TCP;
+    csum = bpf_csum_diff(NULL, 0, (__be32 *)&ph, sizeof(ph), 0);
+    csum = bpf_csum_diff(NULL, 0, (__be32 *)&rst.tcp, sizeof(rst.tcp), csum);
+    rst.tcp.check = xpkt_csum_fold(csum);
+
+    if (bpf_skb_change_tail(skb, pkt->l3_off + sizeof(rst), 0) < 0) {
+        return -1;
+    }
+    return bpf_skb_store_bytes(skb, pkt->l3_off, &rst, sizeof(rst), 0);
+}
+
+/* icmp errors are rate limited per source, their buckets share fsm_xrbkt
+ * with the new flow buckets, apart by the high bit of sys */
+INTERNAL(int)
+xpkt_reject_rate_limit(xpkt_t *pkt)
+{
+    rate_limit_key_t key;
+    rate_limit_bucket_t *bucket;
+    __u64 now, tokens, capacity;
+
+    key.sys = pkt->flow.sys | 0x80000000;
+    XADDR_COPY(key.addr, pkt->flow.saddr);
+
+    now = bpf_ktime_get_ns();
+    capacity = FSM_REJECT_ICMP_INTERVAL * FSM_REJECT_ICMP_BURST;
+    bucket = bpf_map_lookup_elem(&fsm_xrbkt, &key);
+    if (bucket == NULL) {
+        rate_limit_bucket_t init;
+        init.tokens = capacity - FSM_REJECT_ICMP_INTERVAL;
+        init.atime = now;
+        bpf_map_update_elem(&fsm_xrbkt, &key, &init, BPF_NOEXIST);
+        return 0;
+    }
+
+    /* racy without a lock, a few extra errors may pass under contention */
+    tokens = bucket->tokens + (now - bucket->atime);
+    if (tokens > capacity) {
+        tokens = capacity;
+    }
+    bucket->atime = now;
+    if (tokens < FSM_REJECT_ICMP_INTERVAL) {
+        bucket->tokens = tokens;
+        return 1;
+    }
+    bucket->tokens = tokens - FSM_REJECT_ICMP_INTERVAL;
+    return 0;
+}
+
+INTERNAL(int)
+xpkt_reject_udp4(skb_t *skb, xpkt_t *pkt)
+{
+    /* the icmp error quotes the ip header and the udp header of the packet */
+    struct {
+        struct iphdr ip;
+        struct icmphdr icmp;
+        struct iphdr oip;
+        struct udphdr oudp;
+    } __attribute__((packed)) unr;
+    __u32 room = sizeof(unr.ip) + sizeof(unr.icmp);
+    __s64 csum;
+
+    if (pkt->l4_off != pkt->l3_off + sizeof(struct iphdr)) {
+        return -1;
+    }
+    /* no errors to broadcasts and multicasts */
+    if (pkt->flow.daddr4 == 0xffffffff ||
+        (pkt->flow.daddr4 & htonl(0xf0000000)) == htonl(0xe0000000)) {
+        return -1;
+    }
+
+    memset(&unr, 0, sizeof(unr));
+    if (bpf_skb_load_bytes(skb, pkt->l3_off, &unr.oip,
+                           sizeof(unr.oip) + sizeof(unr.oudp)) < 0) {
+        return -1;
+    }
+
+    unr.ip.version = 4;
+    unr.ip.ihl = sizeof(struct iphdr) >> 2;
+    unr.ip.tot_len = htons(sizeof(unr));
+    unr.ip.ttl = FSM_REJECT_TTL;
+    unr.ip.protocol = IPPROTO_ICMP;
+    unr.ip.saddr = pkt->flow.daddr4;
+    unr.ip.daddr = pkt->flow.saddr4;
+    csum = bpf_csum_diff(NULL, 0, (__be32 *)&unr.ip, sizeof(unr.ip), 0);
+    unr.ip.check = xpkt_csum_fold(csum);
+
+    unr.icmp.type = ICMP_DEST_UNREACH;
+    unr.icmp.code = ICMP_PORT_UNREACH;
+    csum = bpf_csum_diff(NULL, 0, (__be32 *)&unr.icmp, sizeof(unr) - sizeof(unr.ip), 0);
+    unr.icmp.checksum = xpkt_csum_fold(csum);
+
+    if (bpf_skb_change_tail(skb, pkt->l4_off + sizeof(unr.oudp), 0) < 0) {
+        return -1;
+    }
+    if (bpf_skb_adjust_room(skb, room, BPF_ADJ_ROOM_MAC, 0) < 0) {
+        return -1;
+    }
+    return bpf_skb_store_bytes(skb, pkt->l3_off, &unr, sizeof(unr), 0);
+}
+
+INTERNAL(int)
+xpkt_reject_udp6(skb_t *skb, xpkt_t *pkt)
+{
+    /* the icmp error quotes the ipv6 header and the udp header of the packet */
+    struct {
+        struct ipv6hdr ip;
+        struct icmp6hdr icmp;
+        struct ipv6hdr oip;
+        struct udphdr oudp;
+    } __attribute__((packed)) unr;
+    struct {
+        __u32 saddr[IP_ALEN];
+        __u32 daddr[IP_ALEN];
+        __be32 len;
+        __u8 zero[3];
+        __u8 nexthdr;
+    } __attribute__((packed)) ph;
+    __u32 room = sizeof(unr.ip) + sizeof(unr.icmp);
+    __u32 icmp_len = sizeof(unr) - sizeof(unr.ip);
+    __s64 csum;
+
+    /* no errors to multicasts */
+    if ((pkt->flow.daddr[0] & htonl(0xff000000)) == htonl(0xff000000)) {
+        return -1;
+    }
+
+    memset(&unr, 0, sizeof(unr));
+    if (bpf_skb_load_bytes(skb, pkt->l3_off, &unr.oip,
+                           sizeof(unr.oip) + sizeof(unr.oudp)) < 0) {
+        return -1;
+    }
+
+    unr.ip.version = 6;
+    unr.ip.payload_len = htons(icmp_len);
+    unr.ip.nexthdr = IPPROTO_ICMPV6;
+    unr.ip.hop_limit = FSM_REJECT_TTL;
+    XADDR_COPY(&unr.ip.saddr, pkt->flow.daddr);
+    XADDR_COPY(&unr.ip.daddr, pkt->flow.saddr);
+
+    unr.icmp.icmp6_type = ICMPV6_DEST_UNREACH;
+    unr.icmp.icmp6_code = ICMPV6_PORT_UNREACH;
+
+    memset(&ph, 0, sizeof(ph));
+    XADDR_COPY(ph.saddr, pkt->flow.daddr);
+    XADDR_COPY(ph.daddr, pkt->flow.saddr);
+    ph.len = htonl(icmp_len);
+    ph.nexthdr = IPPROTO_ICMPV6;
+    csum = bpf_csum_diff(NULL, 0, (__be32 *)&ph, sizeof(ph), 0);
+    csum = bpf_csum_diff(NULL, 0, (__be32 *)&unr.icmp, icmp_len, csum);
+    unr.icmp.icmp6_cksum = xpkt_csum_fold(csum);
+
+    if (bpf_skb_change_tail(skb, pkt->l4_off + sizeof(unr.oudp), 0) < 0) {
+        return -1;
+    }
+    if (bpf_skb_adjust_room(skb, room, BPF_ADJ_ROOM_MAC, 0) < 0) {
+        return -1;
+    }
+    return bpf_skb_store_bytes(skb, pkt->l3_off, &unr, sizeof(unr), 0);
+}
+
+INTERNAL(void)
+xpkt_stat_inc(skb_t *skb, xpkt_t *pkt, __u32 reason)
+{
+    stat_key_t key;
+    stat_t *stat;
+
+    key.sys = pkt->flow.sys;
+    key.reason = reason;
+    stat = bpf_map_lookup_elem(&fsm_xstat, &key);
+    if (stat) {
+        stat->pkts++;
+        stat->bytes += skb->len;
+    } else {
+        stat_t init;
+        init.pkts = 1;
+        init.bytes = skb->len;
+        bpf_map_update_elem(&fsm_xstat, &key, &init, BPF_NOEXIST);
+    }
+}
+
+INTERNAL(void)
+xpkt_trace_event(xpkt_t *pkt, __u8 event, __u8 acl, __s8 trans)
+{
+    tr_event_t *evt;
+
+    if (!pkt->trace_on) {
+        return;
+    }
+
+    evt = bpf_ringbuf_reserve(&fsm_xtrc, sizeof(*evt), 0);
+    if (evt == NULL) {
+        return;
+    }
+
+    evt->ts = bpf_ktime_get_ns();
+    evt->ifi = pkt->ifi;
+    evt->ofi = pkt->ofi;
+    XFLOW_COPY(&evt->flow, &pkt->flow);
+    evt->event = event;
+    evt->tc_dir = pkt->tc_dir;
+    evt->acl = acl;
+    evt->nfs = pkt->nfs[pkt->tc_dir & 1];
+    evt->trans = trans;
+    evt->pad = 0;
+    XADDR_COPY(evt->xaddr, pkt->xaddr);
+    XADDR_COPY(evt->raddr, pkt->raddr);
+    evt->xport = pkt->xport;
+    evt->rport = pkt->rport;
+
+    bpf_ringbuf_submit(evt, 0);
+}
+
 INTERNAL(int)
 xpkt_tail_call(skb_t *skb, xpkt_t *pkt, __u32 prog_id)
 {
@@ -238,6 +560,16 @@ xpkt_tail_call(skb_t *skb, xpkt_t *pkt, __u32 prog_id)
     return TC_ACT_OK;
 }
 
+INTERNAL(int)
+xpkt_tail_call_deny(skb_t *skb, xpkt_t *pkt, flags_t *flags)
+{
+    if (flags->reject_on) {
+        xpkt_tail_call(skb, pkt, FSM_CNI_REJECT_PROG_ID);
+    }
+    /* falls back to drop if the reject prog is missing */
+    return xpkt_tail_call(skb, pkt, FSM_CNI_DROP_PROG_ID);
+}
+
 INTERNAL(int)
 xpkt_spin_lock(struct bpf_spin_lock *lock)
 {
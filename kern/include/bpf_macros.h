@@ -23,6 +23,8 @@
 #define XADDR_COPY(dst, src) memcpy(dst, src, 16)
 #define XADDR_ZERO(v) memset(v, 0, 16)
 #define XADDR_IS_ZERO(v) (v[0] == 0 && v[1] == 0 && v[2] == 0 && v[3] == 0)
+#define XADDR_IS_EQ(a, b)                                                      \
+    (a[0] == b[0] && a[1] == b[1] && a[2] == b[2] && a[3] == b[3])
 
 #define IS_IPv4(v) (v[0] > 0 && v[1] == 0 && v[2] == 0 && v[3] == 0)
 #define IS_IPv6(v) (v[1] != 0 || v[2] != 0 || v[3] != 0)
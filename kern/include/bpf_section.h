@@ -5,6 +5,7 @@
 
 #define TC_PASS "tc"
 #define TC_DROP "tc"
+#define TC_REJECT "tc"
 
 #define TC_FLOW "tc"
 
@@ -21,6 +22,7 @@
 
 #define TC_PASS "classifier/pass"
 #define TC_DROP "classifier/drop"
+#define TC_REJECT "classifier/reject"
 
 #define TC_FLOW "classifier/flow"
 
@@ -37,19 +37,56 @@ xpkt_trace_check(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags)
     }
     return 0;
 trace_on:
-    flags->trace_hdr_on = 1;
-    flags->trace_nat_on = 1;
-    flags->trace_opt_on = 1;
-    flags->trace_acl_on = 1;
-    flags->trace_flow_on = 1;
+    pkt->trace_on = 1;
     return 1;
 }
 
+INTERNAL(acl_op_t *)
+xpkt_acl_range_lookup(acl_key_t *key, __u16 port)
+{
+    acl_range_key_t rkey;
+    acl_range_op_t *rop;
+
+    rkey.prefixlen = ACL_RANGE_FULL_PREFIXLEN;
+    rkey.sys = key->sys;
+    XADDR_COPY(rkey.addr, key->addr);
+    rkey.proto = key->proto;
+    rkey.port = port;
+
+    rop = bpf_map_lookup_elem(&fsm_xarng, &rkey);
+    if (rop == NULL) {
+        return NULL;
+    }
+    return &rop->op;
+}
+
+INTERNAL(acl_op_t *)
+xpkt_acl_cidr_lookup(xpkt_t *pkt, acl_key_t *key, __u16 port)
+{
+    acl_cidr_key_t ckey;
+    acl_op_t *op;
+
+    ckey.prefixlen = ACL_CIDR_FULL_PREFIXLEN;
+    ckey.sys = key->sys;
+    ckey.proto = key->proto;
+    ckey.v6 = pkt->v6;
+    ckey.port = port;
+    XADDR_COPY(ckey.addr, key->addr);
+
+    op = bpf_map_lookup_elem(&fsm_xcidr, &ckey);
+    if (op == NULL && port != 0) {
+        ckey.port = 0;
+        op = bpf_map_lookup_elem(&fsm_xcidr, &ckey);
+    }
+    return op;
+}
+
 INTERNAL(__u8)
 xpkt_acl_check(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags)
 {
     acl_key_t key;
     acl_op_t *op;
+    __u16 port;
 
     key.sys = pkt->flow.sys;
     key.proto = pkt->flow.proto;
@@ -65,7 +102,9 @@ xpkt_acl_check(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags)
             FSM_TRACE_ACL_PRINTF("[ACL] ACL DENY\n");
         }
 #endif
-        xpkt_tail_call(skb, pkt, FSM_CNI_DROP_PROG_ID);
+        xpkt_stat_inc(skb, pkt, STAT_ACL_DENY);
+        xpkt_trace_event(pkt, TRACE_EVENT_ACL, ACL_DENY, TRANS_NON);
+        xpkt_tail_call_deny(skb, pkt, flags);
         return ACL_DENY;
     }
 
@@ -75,10 +114,18 @@ xpkt_acl_check(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags)
     }
 
     if (op == NULL) {
+        port = key.port;
         key.port = 0;
         op = bpf_map_lookup_elem(&fsm_xacl, &key);
         if (op == NULL) {
-            return ACL_AUDIT;
+            /* port ranges of exact addrs, then the longest matched cidr */
+            op = xpkt_acl_range_lookup(&key, port);
+            if (op == NULL) {
+                op = xpkt_acl_cidr_lookup(pkt, &key, port);
+            }
+            if (op == NULL) {
+                return ACL_AUDIT;
+            }
         }
     }
 
@@ -88,6 +135,8 @@ xpkt_acl_check(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags)
             FSM_TRACE_ACL_PRINTF("[ACL] ACL TRUSTED\n");
         }
 #endif
+        xpkt_stat_inc(skb, pkt, STAT_ACL_TRUSTED);
+        xpkt_trace_event(pkt, TRACE_EVENT_ACL, ACL_TRUSTED, TRANS_NON);
         xpkt_tail_call(skb, pkt, FSM_CNI_PASS_PROG_ID);
         return ACL_TRUSTED;
     } else if (op->acl < ACL_AUDIT) {
@@ -96,32 +145,297 @@ xpkt_acl_check(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags)
             FSM_TRACE_ACL_PRINTF("[ACL] ACL DENY\n");
         }
 #endif
-        xpkt_tail_call(skb, pkt, FSM_CNI_DROP_PROG_ID);
+        xpkt_stat_inc(skb, pkt, STAT_ACL_DENY);
+        xpkt_trace_event(pkt, TRACE_EVENT_ACL, ACL_DENY, TRANS_NON);
+        xpkt_tail_call_deny(skb, pkt, flags);
         return ACL_DENY;
     }
 
     return ACL_AUDIT;
 }
 
+INTERNAL(__u32)
+xpkt_hash_mix(__u32 hash, __u32 val)
+{
+    val *= 0xcc9e2d51;
+    val = (val << 15) | (val >> 17);
+    val *= 0x1b873593;
+    hash ^= val;
+    hash = (hash << 13) | (hash >> 19);
+    return hash * 5 + 0xe6546b64;
+}
+
+/* the hash is seedless, so that every node hashes a client tuple the same */
+INTERNAL(__u32)
+xpkt_flow_hash(xpkt_t *pkt)
+{
+    __u32 hash = 0;
+
+    hash = xpkt_hash_mix(hash, pkt->flow.saddr[0]);
+    hash = xpkt_hash_mix(hash, pkt->flow.saddr[1]);
+    hash = xpkt_hash_mix(hash, pkt->flow.saddr[2]);
+    hash = xpkt_hash_mix(hash, pkt->flow.saddr[3]);
+    hash = xpkt_hash_mix(hash, pkt->flow.daddr[0]);
+    hash = xpkt_hash_mix(hash, pkt->flow.daddr[1]);
+    hash = xpkt_hash_mix(hash, pkt->flow.daddr[2]);
+    hash = xpkt_hash_mix(hash, pkt->flow.daddr[3]);
+    hash = xpkt_hash_mix(hash, ((__u32)pkt->flow.sport << 16) | pkt->flow.dport);
+    hash = xpkt_hash_mix(hash, pkt->flow.proto);
+
+    hash ^= hash >> 16;
+    hash *= 0x85ebca6b;
+    hash ^= hash >> 13;
+    hash *= 0xc2b2ae35;
+    hash ^= hash >> 16;
+    return hash;
+}
+
 INTERNAL(int)
-xpkt_flow_nat_endpoint(skb_t *skb, xpkt_t *pkt, nat_op_t *ops)
+xpkt_flow_nat_select(skb_t *skb, xpkt_t *pkt, nat_key_t *key, nat_op_t *ops,
+                     __u8 with_hash)
 {
     int sel = -1;
     __u16 ep_idx = 0, ep_sel = 0;
+    __u32 maglev_idx;
+    nat_maglev_t *maglev;
+    nat_wrr_t *wrr;
     nat_ep_t *ep;
 
+    if (ops->mode == NAT_MODE_MAGLEV && with_hash) {
+        maglev = bpf_map_lookup_elem(&fsm_xmglv, key);
+        if (maglev) {
+            maglev_idx = xpkt_flow_hash(pkt) % FSM_NAT_MAGLEV_SIZE;
+            if (maglev_idx < FSM_NAT_MAGLEV_SIZE) {
+                ep_sel = maglev->eps[maglev_idx];
+                if (ep_sel < ops->ep_cnt && ep_sel < FSM_NAT_MAX_ENDPOINTS &&
+                    ops->eps[ep_sel].active) {
+                    return ep_sel;
+                }
+            }
+        }
+        /* no usable table yet, fall back to round-robin */
+    }
+
+    /* weighted endpoints, or active ones among inactive ones,
+     * are selected along a smooth weighted sequence */
+    wrr = bpf_map_lookup_elem(&fsm_xwrr, key);
+
     xpkt_spin_lock(&ops->lock);
     ep_sel = ops->ep_sel;
-    if (ep_sel < FSM_NAT_MAX_ENDPOINTS) {
+    if (wrr) {
+        if (ep_sel >= wrr->cnt) {
+            ep_sel = 0;
+        }
+        if (ep_sel < FSM_NAT_WRR_SIZE) {
+            sel = wrr->eps[ep_sel];
+            ops->ep_sel = ep_sel + 1;
+            if (sel >= ops->ep_cnt) {
+                sel = -1;
+            }
+        }
+    } else {
+        /* the position may be left along a weighted sequence */
+        if (ep_sel >= FSM_NAT_MAX_ENDPOINTS) {
+            ep_sel = 0;
+        }
         ep = &ops->eps[ep_sel];
         ep_sel = (ep_sel + 1) % ops->ep_cnt;
         ops->ep_sel = ep_sel;
         sel = ep_sel;
     }
     xpkt_spin_unlock(&ops->lock);
+
+    /* inactive endpoints take no new flows */
+    if (sel >= 0 && sel < FSM_NAT_MAX_ENDPOINTS && !ops->eps[sel].active) {
+        sel = -1;
+    }
     return sel;
 }
 
+INTERNAL(__s64)
+xpkt_nat_conns(nat_conn_key_t *key)
+{
+    nat_conn_t *opened, *released;
+    __s64 conns;
+
+    key->released = 0;
+    opened = bpf_map_lookup_elem(&fsm_xconn, key);
+    if (opened == NULL) {
+        return 0;
+    }
+    conns = opened->conns;
+
+    key->released = 1;
+    released = bpf_map_lookup_elem(&fsm_xconn, key);
+    if (released) {
+        conns -= released->conns;
+    }
+    key->released = 0;
+    return conns;
+}
+
+INTERNAL(void)
+xpkt_nat_conn_add(nat_conn_key_t *key, __s64 delta)
+{
+    nat_conn_t *conn, init;
+
+    key->released = 0;
+    conn = bpf_map_lookup_elem(&fsm_xconn, key);
+    if (conn) {
+        __sync_fetch_and_add(&conn->conns, delta);
+        return;
+    }
+    if (delta > 0) {
+        init.conns = delta;
+        if (bpf_map_update_elem(&fsm_xconn, key, &init, BPF_NOEXIST)) {
+            conn = bpf_map_lookup_elem(&fsm_xconn, key);
+            if (conn) {
+                __sync_fetch_and_add(&conn->conns, delta);
+            }
+        }
+    }
+}
+
+/* counts the conn to both the ep and the nat entry */
+INTERNAL(void)
+xpkt_nat_conn_count(nat_conn_key_t *conn, __s64 delta)
+{
+    nat_conn_key_t key;
+
+    memcpy(&key, conn, sizeof(nat_conn_key_t));
+    xpkt_nat_conn_add(&key, delta);
+    XADDR_ZERO(key.raddr);
+    key.rport = 0;
+    xpkt_nat_conn_add(&key, delta);
+}
+
+INTERNAL(int)
+xpkt_flow_nat_ep_full(xpkt_t *pkt, nat_key_t *key, nat_op_t *ops, int sel)
+{
+    nat_conn_key_t ckey;
+    nat_ep_t *ep;
+
+    if (pkt->flow.proto != IPPROTO_TCP || sel < 0 ||
+        sel >= FSM_NAT_MAX_ENDPOINTS) {
+        return 0;
+    }
+    ep = &ops->eps[sel];
+    if (ep->max_conns == 0) {
+        return 0;
+    }
+
+    memcpy(&ckey.nat, key, sizeof(nat_key_t));
+    XADDR_COPY(ckey.raddr, ep->raddr);
+    ckey.rport = ep->rport;
+    return xpkt_nat_conns(&ckey) >= ep->max_conns;
+}
+
+/* an ep at its max conns gives its flow to the next available ep, the eps
+ * are scanned once each from the selected one, as the weighted sequence
+ * repeats heavy eps, new flows are rejected if only full eps are left */
+INTERNAL(int)
+xpkt_flow_nat_select_avail(skb_t *skb, xpkt_t *pkt, nat_key_t *key,
+                           nat_op_t *ops)
+{
+    int sel, full = 0;
+    __u16 n, idx, start = 0;
+
+    sel = xpkt_flow_nat_select(skb, pkt, key, ops, 1);
+    if (sel >= 0) {
+        if (!xpkt_flow_nat_ep_full(pkt, key, ops, sel)) {
+            return sel;
+        }
+        full = 1;
+        start = sel;
+    }
+
+    for (n = 0; n < FSM_NAT_MAX_ENDPOINTS; n++) {
+        if (n >= ops->ep_cnt) {
+            break;
+        }
+        idx = (start + n) % ops->ep_cnt;
+        if (idx == sel || idx >= FSM_NAT_MAX_ENDPOINTS ||
+            !ops->eps[idx].active) {
+            continue;
+        }
+        if (!xpkt_flow_nat_ep_full(pkt, key, ops, idx)) {
+            return idx;
+        }
+        full = 1;
+    }
+    return full ? NAT_EP_FULL : -1;
+}
+
+INTERNAL(int)
+xpkt_flow_nat_endpoint(skb_t *skb, xpkt_t *pkt, nat_key_t *key, nat_op_t *ops)
+{
+    nat_affinity_key_t affi_key;
+    nat_affinity_t *affi, new_affi;
+    nat_ep_t *ep;
+    __u64 now;
+    int sel;
+
+    if (ops->affinity != NAT_AFFINITY_CLIENT_IP) {
+        return xpkt_flow_nat_select_avail(skb, pkt, key, ops);
+    }
+
+    memcpy(&affi_key.nat, key, sizeof(nat_key_t));
+    XADDR_COPY(affi_key.caddr, pkt->flow.saddr);
+    now = bpf_ktime_get_ns();
+
+    /* the endpoint is kept while it is the same one at the same index */
+    affi = bpf_map_lookup_elem(&fsm_xaffi, &affi_key);
+    if (affi && affi->ep_sel < ops->ep_cnt &&
+        affi->ep_sel < FSM_NAT_MAX_ENDPOINTS &&
+        now - affi->atime < (__u64)ops->affinity_timeout * 1000000000ULL) {
+        ep = &ops->eps[affi->ep_sel];
+        if (ep->active && ep->rport == affi->rport &&
+            XADDR_IS_EQ(ep->raddr, affi->raddr) &&
+            !xpkt_flow_nat_ep_full(pkt, key, ops, affi->ep_sel)) {
+            affi->atime = now;
+            return affi->ep_sel;
+        }
+    }
+
+    sel = xpkt_flow_nat_select_avail(skb, pkt, key, ops);
+    if (sel >= 0 && sel < FSM_NAT_MAX_ENDPOINTS) {
+        ep = &ops->eps[sel];
+        memset(&new_affi, 0, sizeof(new_affi));
+        new_affi.atime = now;
+        XADDR_COPY(new_affi.raddr, ep->raddr);
+        new_affi.rport = ep->rport;
+        new_affi.ep_sel = sel;
+        bpf_map_update_elem(&fsm_xaffi, &affi_key, &new_affi, BPF_ANY);
+    }
+    return sel;
+}
+
+INTERNAL(nat_op_t *)
+xpkt_flow_nat_range_lookup(nat_key_t *key)
+{
+    nat_range_key_t rkey;
+    nat_range_t *range;
+
+    rkey.prefixlen = NAT_RANGE_FULL_PREFIXLEN;
+    rkey.sys = key->sys;
+    XADDR_COPY(rkey.daddr, key->daddr);
+    rkey.proto = key->proto;
+    rkey.v6 = key->v6;
+    rkey.tc_dir = key->tc_dir;
+    rkey.dport = key->dport;
+
+    range = bpf_map_lookup_elem(&fsm_xnrng, &rkey);
+    if (range == NULL) {
+        return NULL;
+    }
+
+    /* the nat entry of a dport range is keyed by its bounds */
+    key->dport = range->dport_lo;
+    key->dport_hi = range->dport_hi;
+    return bpf_map_lookup_elem(&fsm_xnat, key);
+}
+
+/* returns 1 if nat-ed, 0 if no usable entry, -1 if its max conns are reached */
 INTERNAL(int)
 xpkt_flow_nat(skb_t *skb, xpkt_t *pkt, flow_t *flow, flow_op_t *op,
               xnat_t *xnat, __u8 with_addr, __u8 with_port)
@@ -147,22 +461,47 @@ xpkt_flow_nat(skb_t *skb, xpkt_t *pkt, flow_t *flow, flow_op_t *op,
     key.proto = pkt->flow.proto;
     key.tc_dir = pkt->tc_dir;
     key.v6 = pkt->v6;
+    key.dport_hi = 0;
 
     ops = bpf_map_lookup_elem(&fsm_xnat, &key);
+    if (!ops && with_port) {
+        ops = xpkt_flow_nat_range_lookup(&key);
+    }
     if (!ops) {
         return 0;
     }
 
-    ep_sel = xpkt_flow_nat_endpoint(skb, pkt, ops);
+    if (ops->max_conns && pkt->flow.proto == IPPROTO_TCP) {
+        nat_conn_key_t ckey;
+        memcpy(&ckey.nat, &key, sizeof(nat_key_t));
+        XADDR_ZERO(ckey.raddr);
+        ckey.rport = 0;
+        if (xpkt_nat_conns(&ckey) >= ops->max_conns) {
+            return -1;
+        }
+    }
+
+    ep_sel = xpkt_flow_nat_endpoint(skb, pkt, &key, ops);
+    if (ep_sel == NAT_EP_FULL) {
+        return -1;
+    }
     if (ep_sel >= 0 && ep_sel < FSM_NAT_MAX_ENDPOINTS) {
         ep = &ops->eps[ep_sel];
         XMAC_COPY(xnat->rmac, ep->rmac);
         XADDR_COPY(xnat->raddr, ep->raddr);
-        xnat->rport = ep->rport;
+        /* eps without port keep the dport, as for dport ranges */
+        xnat->rport = ep->rport ? ep->rport : pkt->flow.dport;
         xnat->ofi = ep->ofi;
         xnat->oflags = ep->oflags;
         pkt->ofi = ep->ofi;
         pkt->oflags = ep->oflags;
+        if (pkt->flow.proto == IPPROTO_TCP) {
+            op->conn_on = 1;
+            memcpy(&op->conn.nat, &key, sizeof(nat_key_t));
+            XADDR_COPY(op->conn.raddr, ep->raddr);
+            op->conn.rport = ep->rport;
+            xpkt_nat_conn_count(&op->conn, 1);
+        }
         if (pkt->tc_dir == TC_DIR_IGR) {
             if (pkt->flow.sys == SYS_E4LB) {
                 if (pkt->ifi == pkt->ofi) {
@@ -261,6 +600,56 @@ xpkt_flow_init_reverse_op(xpkt_t *pkt, cfg_t *cfg, flags_t *flags,
     return 1;
 }
 
+INTERNAL(int)
+xpkt_flow_rate_limit(xpkt_t *pkt)
+{
+    rate_limit_key_t key;
+    rate_limit_t *limit;
+    rate_limit_bucket_t *bucket;
+    __u64 now, tokens, capacity;
+
+    key.sys = pkt->flow.sys;
+    XADDR_COPY(key.addr, pkt->flow.saddr);
+    limit = bpf_map_lookup_elem(&fsm_xrlim, &key);
+    if (limit == NULL) {
+        /* the limit of all sources, still counted per source */
+        XADDR_ZERO(key.addr);
+        limit = bpf_map_lookup_elem(&fsm_xrlim, &key);
+        if (limit == NULL) {
+            return 0;
+        }
+        XADDR_COPY(key.addr, pkt->flow.saddr);
+    }
+    if (limit->interval == 0) {
+        return 0;
+    }
+
+    now = bpf_ktime_get_ns();
+    capacity = limit->interval * limit->burst;
+    bucket = bpf_map_lookup_elem(&fsm_xrbkt, &key);
+    if (bucket == NULL) {
+        rate_limit_bucket_t init;
+        init.tokens = capacity > limit->interval ? capacity - limit->interval : 0;
+        init.atime = now;
+        bpf_map_update_elem(&fsm_xrbkt, &key, &init, BPF_NOEXIST);
+        return 0;
+    }
+
+    /* racy without a lock, a few extra flows may pass under contention */
+    tokens = bucket->tokens + (now - bucket->atime);
+    if (tokens > capacity) {
+        tokens = capacity;
+    }
+    bucket->atime = now;
+    if (tokens < limit->interval) {
+        bucket->tokens = tokens;
+        __sync_fetch_and_add(&limit->drops, 1);
+        return 1;
+    }
+    bucket->tokens = tokens - limit->interval;
+    return 0;
+}
+
 INTERNAL(int)
 xpkt_flow_init_ops(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags,
                    void *fsm_xflow, void *fsm_xopt)
@@ -328,8 +717,26 @@ xpkt_flow_init_ops(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags,
             do_nat = xpkt_flow_nat(skb, pkt, flow, op, &op->xnat, 0, 0);
         }
 
+        if (do_nat < 0) {
+            pkt->nfs[TC_DIR_IGR] = NF_DENY;
+            pkt->nfs[TC_DIR_EGR] = NF_DENY;
+
+#ifndef FSM_TRACE_NAT_OFF
+            if (flags->trace_nat_on) {
+                FSM_TRACE_NAT_PRINTF("[NAT] DROP BY MAX CONNS\n");
+            }
+#endif
+
+            xpkt_stat_inc(skb, pkt, STAT_CONN_LIMIT_DROP);
+            xpkt_trace_event(pkt, TRACE_EVENT_NAT, ACL_AUDIT, TRANS_ERR);
+            xpkt_tail_call(skb, pkt, FSM_CNI_DROP_PROG_ID);
+            return 0;
+        }
+
         if (!do_nat) {
             if (flags->tcp_proto_allow_nat_escape) {
+                xpkt_stat_inc(skb, pkt, STAT_NAT_ESCAPE);
+                xpkt_trace_event(pkt, TRACE_EVENT_NAT, ACL_AUDIT, TRANS_NON);
                 xpkt_tail_call(skb, pkt, FSM_CNI_PASS_PROG_ID);
             } else {
                 pkt->nfs[TC_DIR_IGR] = NF_DENY;
@@ -341,7 +748,9 @@ xpkt_flow_init_ops(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags,
                 }
 #endif
 
-                xpkt_tail_call(skb, pkt, FSM_CNI_DROP_PROG_ID);
+                xpkt_stat_inc(skb, pkt, STAT_NO_NAT_DROP);
+                xpkt_trace_event(pkt, TRACE_EVENT_NAT, ACL_AUDIT, TRANS_ERR);
+                xpkt_tail_call_deny(skb, pkt, flags);
             }
             return 0;
         }
@@ -361,6 +770,8 @@ xpkt_flow_init_ops(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags,
 
         if (!do_nat) {
             if (flags->udp_proto_allow_nat_escape) {
+                xpkt_stat_inc(skb, pkt, STAT_NAT_ESCAPE);
+                xpkt_trace_event(pkt, TRACE_EVENT_NAT, ACL_AUDIT, TRANS_NON);
                 xpkt_tail_call(skb, pkt, FSM_CNI_PASS_PROG_ID);
             } else {
                 pkt->nfs[TC_DIR_IGR] = NF_DENY;
@@ -372,12 +783,37 @@ xpkt_flow_init_ops(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags,
                 }
 #endif
 
-                xpkt_tail_call(skb, pkt, FSM_CNI_DROP_PROG_ID);
+                xpkt_stat_inc(skb, pkt, STAT_NO_NAT_DROP);
+                xpkt_trace_event(pkt, TRACE_EVENT_NAT, ACL_AUDIT, TRANS_ERR);
+                xpkt_tail_call_deny(skb, pkt, flags);
             }
             return 0;
         }
     }
 
+    /* only the packets about to insert a flow spend tokens,
+     * tcp flows are created by syns, retransmitted ones included */
+    if ((pkt->flow.proto != IPPROTO_TCP ||
+         (pkt->tcp_flags & (TCP_F_SYN | TCP_F_ACK)) == TCP_F_SYN) &&
+        xpkt_flow_rate_limit(pkt)) {
+        if (op->conn_on) {
+            xpkt_nat_conn_count(&op->conn, -1);
+        }
+        pkt->nfs[TC_DIR_IGR] = NF_DENY;
+        pkt->nfs[TC_DIR_EGR] = NF_DENY;
+
+#ifndef FSM_TRACE_FLOW_OFF
+        if (flags->trace_flow_on) {
+            FSM_TRACE_FLOW_PRINTF("[FLW] DROP BY RATE LIMIT\n");
+        }
+#endif
+
+        xpkt_stat_inc(skb, pkt, STAT_RATE_LIMIT_DROP);
+        xpkt_trace_event(pkt, TRACE_EVENT_FLOW, ACL_AUDIT, TRANS_ERR);
+        xpkt_tail_call(skb, pkt, FSM_CNI_DROP_PROG_ID);
+        return 0;
+    }
+
     if (flags->tcp_nat_opt_on && pkt->flow.proto == IPPROTO_TCP) {
         if (XFLAG_HAS(op->nfs[TC_DIR_EGR], NF_XNAT)) {
             opt_key_t opt;
@@ -445,7 +881,15 @@ xpkt_flow_init_ops(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags,
         }
     }
 
-    bpf_map_update_elem(fsm_xflow, flow, op, BPF_ANY);
+    /* the conn counted by xpkt_flow_nat is given back if another cpu
+     * inserted the flow first or the map is full, a lost race carries
+     * on with the flow and reverse flow of the winner */
+    if (bpf_map_update_elem(fsm_xflow, flow, op, BPF_NOEXIST)) {
+        if (op->conn_on) {
+            xpkt_nat_conn_count(&op->conn, -1);
+        }
+        return 1;
+    }
 
 #ifndef FSM_TRACE_FLOW_OFF
     if (flags->trace_flow_on) {
@@ -465,8 +909,10 @@ xpkt_flow_proc(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags,
         return TRANS_ERR;
     }
     flow_t flow, rflow;
-    flow_op_t *op, *rop;
+    flow_op_t *op, *rop, *cop;
     opt_key_t opt;
+    nat_conn_key_t conn;
+    __u8 conn_on;
     __s8 trans = TRANS_ERR;
 
     XFLOW_COPY(&flow, &pkt->flow);
@@ -689,8 +1135,20 @@ flow_track:
 #endif
                 }
             }
-            bpf_map_delete_elem(fsm_xflow, &rflow);
-            bpf_map_delete_elem(fsm_xflow, &flow);
+            /* the conn is released once, by whoever deletes the c2s flow */
+            cop = op->flow_dir == FLOW_DIR_C2S ? op : rop;
+            conn_on = cop->conn_on;
+            if (conn_on) {
+                memcpy(&conn, &cop->conn, sizeof(nat_conn_key_t));
+            }
+            if (bpf_map_delete_elem(fsm_xflow, &rflow) == 0 && conn_on &&
+                op->flow_dir != FLOW_DIR_C2S) {
+                xpkt_nat_conn_count(&conn, -1);
+            }
+            if (bpf_map_delete_elem(fsm_xflow, &flow) == 0 && conn_on &&
+                op->flow_dir == FLOW_DIR_C2S) {
+                xpkt_nat_conn_count(&conn, -1);
+            }
 
 #ifndef FSM_TRACE_FLOW_OFF
             if (flags->trace_flow_on) {
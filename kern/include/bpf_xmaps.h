@@ -52,6 +52,76 @@ struct {
 } fsm_xacl SEC(".maps");
 #endif
 
+#ifdef LEGACY_BPF_MAPS
+struct bpf_map_def SEC("maps") fsm_xcidr = {
+    .type = BPF_MAP_TYPE_LPM_TRIE,
+    .key_size = sizeof(acl_cidr_key_t),
+    .value_size = sizeof(acl_op_t),
+    .max_entries = FSM_ACL_CIDR_MAP_ENTRIES,
+    .map_flags = BPF_F_NO_PREALLOC,
+};
+#else /* BTF definitions */
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __type(key, acl_cidr_key_t);
+    __type(value, acl_op_t);
+    __uint(max_entries, FSM_ACL_CIDR_MAP_ENTRIES);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+} fsm_xcidr SEC(".maps");
+#endif
+
+#ifdef LEGACY_BPF_MAPS
+struct bpf_map_def SEC("maps") fsm_xarng = {
+    .type = BPF_MAP_TYPE_LPM_TRIE,
+    .key_size = sizeof(acl_range_key_t),
+    .value_size = sizeof(acl_range_op_t),
+    .max_entries = FSM_ACL_RANGE_MAP_ENTRIES,
+    .map_flags = BPF_F_NO_PREALLOC,
+};
+#else /* BTF definitions */
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __type(key, acl_range_key_t);
+    __type(value, acl_range_op_t);
+    __uint(max_entries, FSM_ACL_RANGE_MAP_ENTRIES);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+} fsm_xarng SEC(".maps");
+#endif
+
+#ifdef LEGACY_BPF_MAPS
+struct bpf_map_def SEC("maps") fsm_xrlim = {
+    .type = BPF_MAP_TYPE_HASH,
+    .key_size = sizeof(rate_limit_key_t),
+    .value_size = sizeof(rate_limit_t),
+    .max_entries = FSM_RATE_LIMIT_MAP_ENTRIES,
+    .map_flags = BPF_F_NO_PREALLOC,
+};
+#else /* BTF definitions */
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __type(key, rate_limit_key_t);
+    __type(value, rate_limit_t);
+    __uint(max_entries, FSM_RATE_LIMIT_MAP_ENTRIES);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+} fsm_xrlim SEC(".maps");
+#endif
+
+#ifdef LEGACY_BPF_MAPS
+struct bpf_map_def SEC("maps") fsm_xrbkt = {
+    .type = BPF_MAP_TYPE_LRU_HASH,
+    .key_size = sizeof(rate_limit_key_t),
+    .value_size = sizeof(rate_limit_bucket_t),
+    .max_entries = FSM_RATE_BUCKET_MAP_ENTRIES,
+};
+#else /* BTF definitions */
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __type(key, rate_limit_key_t);
+    __type(value, rate_limit_bucket_t);
+    __uint(max_entries, FSM_RATE_BUCKET_MAP_ENTRIES);
+} fsm_xrbkt SEC(".maps");
+#endif
+
 #ifdef LEGACY_BPF_MAPS
 struct bpf_map_def SEC("maps") fsm_xnat = {
     .type = BPF_MAP_TYPE_HASH,
@@ -68,6 +138,94 @@ struct {
 } fsm_xnat SEC(".maps");
 #endif
 
+#ifdef LEGACY_BPF_MAPS
+struct bpf_map_def SEC("maps") fsm_xnrng = {
+    .type = BPF_MAP_TYPE_LPM_TRIE,
+    .key_size = sizeof(nat_range_key_t),
+    .value_size = sizeof(nat_range_t),
+    .max_entries = FSM_NAT_RANGE_MAP_ENTRIES,
+    .map_flags = BPF_F_NO_PREALLOC,
+};
+#else /* BTF definitions */
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __type(key, nat_range_key_t);
+    __type(value, nat_range_t);
+    __uint(max_entries, FSM_NAT_RANGE_MAP_ENTRIES);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+} fsm_xnrng SEC(".maps");
+#endif
+
+#ifdef LEGACY_BPF_MAPS
+struct bpf_map_def SEC("maps") fsm_xconn = {
+    .type = BPF_MAP_TYPE_HASH,
+    .key_size = sizeof(nat_conn_key_t),
+    .value_size = sizeof(nat_conn_t),
+    .max_entries = FSM_NAT_CONN_MAP_ENTRIES,
+    .map_flags = BPF_F_NO_PREALLOC,
+};
+#else /* BTF definitions */
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __type(key, nat_conn_key_t);
+    __type(value, nat_conn_t);
+    __uint(max_entries, FSM_NAT_CONN_MAP_ENTRIES);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+} fsm_xconn SEC(".maps");
+#endif
+
+#ifdef LEGACY_BPF_MAPS
+struct bpf_map_def SEC("maps") fsm_xmglv = {
+    .type = BPF_MAP_TYPE_HASH,
+    .key_size = sizeof(nat_key_t),
+    .value_size = sizeof(nat_maglev_t),
+    .max_entries = FSM_NAT_MAP_ENTRIES,
+    .map_flags = BPF_F_NO_PREALLOC,
+};
+#else /* BTF definitions */
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __type(key, nat_key_t);
+    __type(value, nat_maglev_t);
+    __uint(max_entries, FSM_NAT_MAP_ENTRIES);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+} fsm_xmglv SEC(".maps");
+#endif
+
+#ifdef LEGACY_BPF_MAPS
+struct bpf_map_def SEC("maps") fsm_xwrr = {
+    .type = BPF_MAP_TYPE_HASH,
+    .key_size = sizeof(nat_key_t),
+    .value_size = sizeof(nat_wrr_t),
+    .max_entries = FSM_NAT_MAP_ENTRIES,
+    .map_flags = BPF_F_NO_PREALLOC,
+};
+#else /* BTF definitions */
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __type(key, nat_key_t);
+    __type(value, nat_wrr_t);
+    __uint(max_entries, FSM_NAT_MAP_ENTRIES);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+} fsm_xwrr SEC(".maps");
+#endif
+
+#ifdef LEGACY_BPF_MAPS
+struct bpf_map_def SEC("maps") fsm_xaffi = {
+    .type = BPF_MAP_TYPE_LRU_HASH,
+    .key_size = sizeof(nat_affinity_key_t),
+    .value_size = sizeof(nat_affinity_t),
+    .max_entries = FSM_NAT_AFFINITY_MAP_ENTRIES,
+};
+#else /* BTF definitions */
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __type(key, nat_affinity_key_t);
+    __type(value, nat_affinity_t);
+    __uint(max_entries, FSM_NAT_AFFINITY_MAP_ENTRIES);
+} fsm_xaffi SEC(".maps");
+#endif
+
 #ifdef LEGACY_BPF_MAPS
 struct bpf_map_def SEC("maps") fsm_xflop = {
     .type = BPF_MAP_TYPE_PERCPU_ARRAY,
@@ -207,6 +365,18 @@ struct {
 } fsm_trpt SEC(".maps");
 #endif
 
+#ifdef LEGACY_BPF_MAPS
+struct bpf_map_def SEC("maps") fsm_xtrc = {
+    .type = BPF_MAP_TYPE_RINGBUF,
+    .max_entries = FSM_TRACE_RINGBUF_SIZE,
+};
+#else /* BTF definitions */
+struct {
+    __uint(type, BPF_MAP_TYPE_RINGBUF);
+    __uint(max_entries, FSM_TRACE_RINGBUF_SIZE);
+} fsm_xtrc SEC(".maps");
+#endif
+
 #ifdef LEGACY_BPF_MAPS
 struct bpf_map_def SEC("maps") fsm_xifs = {
     .type = BPF_MAP_TYPE_HASH,
@@ -225,4 +395,20 @@ struct {
 } fsm_xifs SEC(".maps");
 #endif
 
+#ifdef LEGACY_BPF_MAPS
+struct bpf_map_def SEC("maps") fsm_xstat = {
+    .type = BPF_MAP_TYPE_PERCPU_HASH,
+    .key_size = sizeof(stat_key_t),
+    .value_size = sizeof(stat_t),
+    .max_entries = FSM_STAT_MAP_ENTRIES,
+};
+#else /* BTF definitions */
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_HASH);
+    __type(key, stat_key_t);
+    __type(value, stat_t);
+    __uint(max_entries, FSM_STAT_MAP_ENTRIES);
+} fsm_xstat SEC(".maps");
+#endif
+
 #endif
\ No newline at end of file
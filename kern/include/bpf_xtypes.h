@@ -50,6 +50,7 @@ typedef struct xpkt_flags_t {
     __u64 trace_flow_on : 1;
     __u64 trace_by_ip_on : 1;
     __u64 trace_by_port_on : 1;
+    __u64 reject_on : 1;
 } __attribute__((packed)) flags_t;
 
 typedef struct xpkt_cfg_t {
@@ -107,6 +108,7 @@ typedef struct xpkt {
     __u8 flow_dir : 2;
     __u8 l4_fin : 1;
     __u8 re_flow : 1;
+    __u8 trace_on : 1;
 
     __u16 l2_type;
     __u8 dmac[ETH_ALEN];
@@ -218,17 +220,6 @@ typedef struct xpkt_xnat_t {
     __u32 oflags;
 } xnat_t;
 
-typedef struct xpkt_flow_op_t {
-    struct bpf_spin_lock lock;
-    __u8 flow_dir;
-    __u8 fin;
-    nf_t nfs[TC_DIR_MAX];
-    __u64 atime;
-    xnat_t xnat;
-    trans_t trans;
-    __u8 do_trans;
-} flow_op_t;
-
 typedef struct {
     sys_t sys;
     __u32 daddr[IP_ALEN];
@@ -236,8 +227,29 @@ typedef struct {
     __u8 proto;
     __u8 v6;
     __u8 tc_dir;
+    /* non-zero for the entry of the dport range [dport, dport_hi] */
+    __u16 dport_hi;
 } __attribute__((packed)) nat_key_t;
 
+typedef struct {
+    __u32 prefixlen;
+    sys_t sys;
+    __u32 daddr[IP_ALEN];
+    __u8 proto;
+    __u8 v6;
+    __u8 tc_dir;
+    __u16 dport;
+} __attribute__((packed)) nat_range_key_t;
+
+/* a dport range is split into dport prefixes after the exactly matched fields */
+#define NAT_RANGE_FIXED_PREFIXLEN (184)
+#define NAT_RANGE_FULL_PREFIXLEN (NAT_RANGE_FIXED_PREFIXLEN + 16)
+
+typedef struct {
+    __u16 dport_lo;
+    __u16 dport_hi;
+} nat_range_t;
+
 typedef struct {
     __u32 raddr[IP_ALEN];
     __u16 rport;
@@ -247,15 +259,88 @@ typedef struct {
     __u8 omac[ETH_ALEN];
     __u8 omac_set;
     __u8 active;
+    __u16 weight;
+    /* unix seconds the inactive ep is drained until, kept by the control plane */
+    __u32 drain_deadline;
+    /* max concurrent tcp conns, 0 for unlimited */
+    __u32 max_conns;
 } nat_ep_t;
 
+typedef enum xpkt_nat_mode_e {
+    NAT_MODE_RR = 0,
+    NAT_MODE_MAGLEV = 1,
+    NAT_MODE_MAX
+} nat_mode_e;
+
+typedef enum xpkt_nat_affinity_e {
+    NAT_AFFINITY_NONE = 0,
+    NAT_AFFINITY_CLIENT_IP = 1,
+    NAT_AFFINITY_MAX
+} nat_affinity_e;
+
 typedef struct {
     struct bpf_spin_lock lock;
     __u16 ep_sel;
     __u16 ep_cnt;
+    __u8 mode;
+    __u8 affinity;
+    __u32 affinity_timeout;
+    /* max concurrent tcp conns of all eps, 0 for unlimited */
+    __u32 max_conns;
     nat_ep_t eps[FSM_NAT_MAX_ENDPOINTS];
 } nat_op_t;
 
+typedef struct {
+    nat_key_t nat;
+    __u32 caddr[IP_ALEN];
+} __attribute__((packed)) nat_affinity_key_t;
+
+typedef struct {
+    __u64 atime;
+    __u32 raddr[IP_ALEN];
+    __u16 rport;
+    __u16 ep_sel;
+} nat_affinity_t;
+
+/* the conns of the nat entry are keyed by zero raddr and rport */
+typedef struct {
+    nat_key_t nat;
+    __u32 raddr[IP_ALEN];
+    __u16 rport;
+    /* 1 for the conns released by the control plane, which are counted apart,
+     * so that the datapath and the control plane never write the same value */
+    __u8 released;
+} __attribute__((packed)) nat_conn_key_t;
+
+typedef struct {
+    __s64 conns;
+} nat_conn_t;
+
+#define NAT_EP_FULL (-2)
+
+typedef struct xpkt_flow_op_t {
+    struct bpf_spin_lock lock;
+    __u8 flow_dir;
+    __u8 fin;
+    nf_t nfs[TC_DIR_MAX];
+    __u64 atime;
+    xnat_t xnat;
+    trans_t trans;
+    __u8 do_trans;
+    /* the c2s op of a counted tcp conn keeps where it is counted */
+    __u8 conn_on;
+    nat_conn_key_t conn;
+} flow_op_t;
+
+typedef struct {
+    __u8 eps[FSM_NAT_MAGLEV_SIZE];
+} nat_maglev_t;
+
+typedef struct {
+    __u16 cnt;
+    __u8 eps[FSM_NAT_WRR_SIZE];
+} nat_wrr_t;
+
 typedef struct xpkt_opt_key_t {
     sys_t sys;
     __u32 laddr[IP_ALEN];
@@ -273,6 +358,31 @@ typedef struct xpkt_acl_key_t {
     __u8 proto;
 } __attribute__((packed)) acl_key_t;
 
+typedef struct xpkt_acl_cidr_key_t {
+    __u32 prefixlen;
+    sys_t sys;
+    __u8 proto;
+    __u8 v6;
+    __u16 port;
+    __u32 addr[IP_ALEN];
+} __attribute__((packed)) acl_cidr_key_t;
+
+/* sys, proto, v6 and port are always matched before the addr prefix */
+#define ACL_CIDR_FIXED_PREFIXLEN (64)
+#define ACL_CIDR_FULL_PREFIXLEN (ACL_CIDR_FIXED_PREFIXLEN + 128)
+
+typedef struct xpkt_acl_range_key_t {
+    __u32 prefixlen;
+    sys_t sys;
+    __u32 addr[IP_ALEN];
+    __u8 proto;
+    __u16 port;
+} __attribute__((packed)) acl_range_key_t;
+
+/* a port range is split into port prefixes after the exactly matched fields */
+#define ACL_RANGE_FIXED_PREFIXLEN (168)
+#define ACL_RANGE_FULL_PREFIXLEN (ACL_RANGE_FIXED_PREFIXLEN + 16)
+
 typedef enum xpkt_acl_op_e {
     ACL_DENY = 0,
     ACL_AUDIT = 1,
@@ -285,6 +395,29 @@ typedef struct xpkt_acl_op_t {
     __u16 id;
 } __attribute__((packed)) acl_op_t;
 
+typedef struct xpkt_acl_range_op_t {
+    acl_op_t op;
+    __u16 port_lo;
+    __u16 port_hi;
+} __attribute__((packed)) acl_range_op_t;
+
+typedef struct xpkt_rate_limit_key_t {
+    sys_t sys;
+    __u32 addr[IP_ALEN];
+} __attribute__((packed)) rate_limit_key_t;
+
+typedef struct {
+    __u32 rate;
+    __u32 burst;
+    __u64 interval; /* nanoseconds per new flow, set by control plane */
+    __u64 drops;
+} rate_limit_t;
+
+typedef struct {
+    __u64 tokens; /* in nanoseconds of interval */
+    __u64 atime;
+} rate_limit_bucket_t;
+
 typedef struct xpkt_trace_ip_t {
     sys_t sys;
     __u32 addr[IP_ALEN];
@@ -299,6 +432,29 @@ typedef struct xpkt_trace_op_t {
     __u8 tc_dir[TC_DIR_MAX];
 } __attribute__((packed)) tr_op_t;
 
+typedef enum xpkt_trace_event_e {
+    TRACE_EVENT_ACL = 0,
+    TRACE_EVENT_NAT = 1,
+    TRACE_EVENT_FLOW = 2,
+} trace_event_e;
+
+typedef struct xpkt_trace_event_t {
+    __u64 ts;
+    __u32 ifi;
+    __u32 ofi;
+    flow_t flow;
+    __u8 event;
+    __u8 tc_dir;
+    __u8 acl;
+    __u8 nfs;
+    __s8 trans;
+    __u8 pad;
+    __u32 xaddr[IP_ALEN];
+    __u32 raddr[IP_ALEN];
+    __u16 xport;
+    __u16 rport;
+} __attribute__((packed)) tr_event_t;
+
 typedef struct xpkt_if_name_t {
     __u8 len;
     __u8 name[IFNAMSIZ];
@@ -310,4 +466,29 @@ typedef struct xpkt_if_info_t {
     __u8 mac[ETH_ALEN];
     __u8 xmac[ETH_ALEN];
 } __attribute__((packed)) if_info_t;
+
+typedef enum xpkt_stat_reason_e {
+    STAT_PKTS = 0,
+    STAT_NAT = 1,
+    STAT_RDIR = 2,
+    STAT_DENY = 3,
+    STAT_ACL_DENY = 4,
+    STAT_ACL_TRUSTED = 5,
+    STAT_NO_NAT_DROP = 6,
+    STAT_NAT_ESCAPE = 7,
+    STAT_RATE_LIMIT_DROP = 8,
+    STAT_CONN_LIMIT_DROP = 9,
+    STAT_REJECT = 10,
+    STAT_MAX
+} stat_reason_e;
+
+typedef struct xpkt_stat_key_t {
+    sys_t sys;
+    __u32 reason;
+} __attribute__((packed)) stat_key_t;
+
+typedef struct xpkt_stat_t {
+    __u64 pkts;
+    __u64 bytes;
+} stat_t;
 #endif
\ No newline at end of file
@@ -33,9 +33,53 @@ int drop(skb_t *skb)
     return TC_ACT_SHOT;
 }
 
+SEC(TC_REJECT)
+int reject(skb_t *skb)
+{
+    int z = 0;
+    int ret = -1;
+    xpkt_t *pkt = bpf_map_lookup_elem(&fsm_xpkt, &z);
+    if (!pkt) {
+        return TC_ACT_SHOT;
+    }
+
+    /* only unfragmented eth frames are answered, others are dropped */
+    if (pkt->l3_off != sizeof(struct ethhdr) || pkt->ipv4_frag ||
+        pkt->re_flow) {
+        return TC_ACT_SHOT;
+    }
+
+    if (pkt->flow.proto == IPPROTO_TCP) {
+        /* a rst only answers a syn, never a reply or a rst */
+        if ((pkt->tcp_flags & (TCP_F_SYN | TCP_F_ACK | TCP_F_RST)) !=
+            TCP_F_SYN) {
+            return TC_ACT_SHOT;
+        }
+        ret = pkt->v6 ? xpkt_reject_tcp6(skb, pkt) : xpkt_reject_tcp4(skb, pkt);
+    } else if (pkt->flow.proto == IPPROTO_UDP) {
+        /* a flood of denied datagrams is not answered by as many errors */
+        if (xpkt_reject_rate_limit(pkt)) {
+            return TC_ACT_SHOT;
+        }
+        ret = pkt->v6 ? xpkt_reject_udp6(skb, pkt) : xpkt_reject_udp4(skb, pkt);
+    }
+    if (ret < 0 || xpkt_reject_eth(skb, pkt) < 0) {
+        return TC_ACT_SHOT;
+    }
+
+    xpkt_stat_inc(skb, pkt, STAT_REJECT);
+
+    if (pkt->tc_dir == TC_DIR_EGR) {
+        /* the sender is local, the reply goes back up the stack */
+        return bpf_redirect(pkt->ifi, BPF_F_INGRESS);
+    }
+    return bpf_redirect(pkt->ifi, 0);
+}
+
 INTERNAL(int) dispatch(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags)
 {
     if (XFLAG_HAS(pkt->nfs[pkt->tc_dir], NF_XNAT)) {
+        xpkt_stat_inc(skb, pkt, STAT_NAT);
         if (pkt->flow.proto == IPPROTO_TCP) {
 #ifndef FSM_TRACE_NAT_OFF
             if (flags->trace_nat_on) {
@@ -142,6 +186,7 @@ INTERNAL(int) dispatch(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags)
     }
 
     if (XFLAG_HAS(pkt->nfs[pkt->tc_dir], NF_RDIR)) {
+        xpkt_stat_inc(skb, pkt, STAT_RDIR);
         if (pkt->ofi > 0) {
 
 #ifndef FSM_TRACE_NAT_OFF
@@ -164,6 +209,7 @@ INTERNAL(int) dispatch(skb_t *skb, xpkt_t *pkt, cfg_t *cfg, flags_t *flags)
     }
 
     if (pkt->nfs[pkt->tc_dir] == NF_DENY) {
+        xpkt_stat_inc(skb, pkt, STAT_DENY);
         return TC_ACT_SHOT;
     } else if (XFLAG_HAS(pkt->nfs[pkt->tc_dir], NF_ALLOW)) {
 #ifndef FSM_TRACE_NAT_OFF
@@ -351,6 +397,8 @@ INTERNAL(int) process(skb_t *skb, xpkt_t *pkt)
         return TC_ACT_OK;
     }
 
+    xpkt_stat_inc(skb, pkt, STAT_PKTS);
+
     if (flags->acl_check_on) {
         xpkt_acl_check(skb, pkt, cfg, flags);
 #ifndef FSM_TRACE_ACL_OFF
@@ -367,6 +415,7 @@ INTERNAL(int) process(skb_t *skb, xpkt_t *pkt)
         FSM_TRACE_HDR_PRINTF("[HDR] TRANS: %d\n", trans);
     }
 #endif
+    xpkt_trace_event(pkt, TRACE_EVENT_FLOW, ACL_AUDIT, trans);
     return dispatch(skb, pkt, cfg, flags);
 #else
     xpkt_tail_call(skb, pkt, FSM_CNI_FLOW_PROG_ID);
@@ -592,6 +641,7 @@ int flow(skb_t *skb)
         FSM_TRACE_HDR_PRINTF("[HDR] TRANS: %d\n", trans);
     }
 #endif
+    xpkt_trace_event(pkt, TRACE_EVENT_FLOW, ACL_AUDIT, trans);
 
     return dispatch(skb, pkt, cfg, flags);
 #else
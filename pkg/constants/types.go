@@ -19,4 +19,16 @@ const (
 
 	// FSMKubeResourceMonitorAnnotation is the key of the annotation used to monitor a K8s resource
 	FSMKubeResourceMonitorAnnotation = "flomesh.io/monitored-by"
+
+	// E4lbLoadBalancerClass is the load balancer class of services served by e4lb
+	E4lbLoadBalancerClass = "flomesh.io/e4lb"
+
+	// E4lbVipPoolAnnotation is the annotation used to select the vip pool of a service
+	E4lbVipPoolAnnotation = "flomesh.io/e4lb-vip-pool"
+
+	// E4lbVipAnnotation is the annotation used to request a specific vip for a service
+	E4lbVipAnnotation = "flomesh.io/e4lb-vip"
+
+	// E4lbModeAnnotation is the annotation used to select the endpoint selection mode of a service: rr or maglev
+	E4lbModeAnnotation = "flomesh.io/e4lb-mode"
 )
@@ -1,12 +1,14 @@
 package k8s
 
 import (
+	"bytes"
 	"cmp"
 	"fmt"
 	"net"
 	"slices"
 
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 
 	"github.com/flomesh-io/xnet/pkg/constants"
 	"github.com/flomesh-io/xnet/pkg/k8s/informers"
@@ -28,6 +30,9 @@ func newClient(informerCollection *informers.InformerCollection, msgBroker *mess
 		meshExcludeNamespaces: meshExcludeNamespaces,
 	}
 	c.initSidecarPodMonitor()
+	c.initPodMonitor()
+	c.initNamespaceMonitor()
+	c.initServiceMonitor()
 	return c
 }
 
@@ -78,6 +83,15 @@ func (c *client) IsMonitoredPod(pod string, namespace string) bool {
 	return false
 }
 
+// GetPod returns a Pod resource if found, nil otherwise.
+func (c *client) GetPod(pod string, namespace string) *corev1.Pod {
+	podIf, exists, err := c.informers.GetByKey(informers.InformerKeyPod, fmt.Sprintf("%s/%s", namespace, pod))
+	if exists && err == nil {
+		return podIf.(*corev1.Pod)
+	}
+	return nil
+}
+
 // ListAllPods returns all pods
 func (c *client) ListAllPods() []*corev1.Pod {
 	var pods []*corev1.Pod
@@ -122,14 +136,55 @@ func (c *client) ListSidecarPods() []*corev1.Pod {
 	}
 
 	pods = slices.SortedFunc[*corev1.Pod](slices.Values(pods), func(e1 *corev1.Pod, e2 *corev1.Pod) int {
-		n1, _ := util.IPv4ToInt(net.ParseIP(e1.Status.PodIP))
-		n2, _ := util.IPv4ToInt(net.ParseIP(e2.Status.PodIP))
-		return cmp.Compare(n1, n2)
+		return comparePodIP(net.ParseIP(e1.Status.PodIP), net.ParseIP(e2.Status.PodIP))
 	})
 
 	return pods
 }
 
+// ListServices returns all services
+func (c *client) ListServices() []*corev1.Service {
+	var services []*corev1.Service
+	for _, svcInterface := range c.informers.List(informers.InformerKeyService) {
+		svc := svcInterface.(*corev1.Service)
+		services = append(services, svc)
+	}
+	return services
+}
+
+// ListServiceEndpointSlices returns the endpoint slices of the service
+func (c *client) ListServiceEndpointSlices(svc *corev1.Service) []*discoveryv1.EndpointSlice {
+	var endpointSlices []*discoveryv1.EndpointSlice
+	for _, epsInterface := range c.informers.List(informers.InformerKeyEndpointSlice) {
+		eps := epsInterface.(*discoveryv1.EndpointSlice)
+		if eps.Namespace != svc.Namespace {
+			continue
+		}
+		if eps.Labels[discoveryv1.LabelServiceName] != svc.Name {
+			continue
+		}
+		endpointSlices = append(endpointSlices, eps)
+	}
+	return endpointSlices
+}
+
+// comparePodIP orders ipv4 addrs before ipv6 addrs
+func comparePodIP(ip1, ip2 net.IP) int {
+	ip1v4, ip2v4 := ip1.To4(), ip2.To4()
+	switch {
+	case ip1v4 != nil && ip2v4 != nil:
+		n1, _ := util.IPv4ToInt(ip1v4)
+		n2, _ := util.IPv4ToInt(ip2v4)
+		return cmp.Compare(n1, n2)
+	case ip1v4 != nil:
+		return -1
+	case ip2v4 != nil:
+		return 1
+	default:
+		return bytes.Compare(ip1.To16(), ip2.To16())
+	}
+}
+
 // Function to filter K8s meta Objects by FSM's isMonitoredNamespace
 func (c *client) shouldObserveSidecarPod(obj interface{}) bool {
 	//object, ok := obj.(metav1.Object)
@@ -149,3 +204,43 @@ func (c *client) initSidecarPodMonitor() {
 	c.informers.AddEventHandler(informers.InformerKeySidecarPod,
 		GetEventHandlerFuncs(c.shouldObserveSidecarPod, sidecarPodEventTypes, c.msgBroker))
 }
+
+func (c *client) initPodMonitor() {
+	podEventTypes := EventTypes{
+		Add:    kind.PodAdded,
+		Update: kind.PodUpdated,
+		Delete: kind.PodDeleted,
+	}
+	c.informers.AddEventHandler(informers.InformerKeyPod,
+		GetEventHandlerFuncs(nil, podEventTypes, c.msgBroker))
+}
+
+// initNamespaceMonitor publishes the namespaces entering and leaving the monitored ones,
+// the namespace informer only lists the namespaces labelled for the mesh.
+func (c *client) initNamespaceMonitor() {
+	namespaceEventTypes := EventTypes{
+		Add:    kind.NamespaceAdded,
+		Update: kind.NamespaceUpdated,
+		Delete: kind.NamespaceDeleted,
+	}
+	c.informers.AddEventHandler(informers.InformerKeyNamespace,
+		GetEventHandlerFuncs(nil, namespaceEventTypes, c.msgBroker))
+}
+
+func (c *client) initServiceMonitor() {
+	serviceEventTypes := EventTypes{
+		Add:    kind.ServiceAdded,
+		Update: kind.ServiceUpdated,
+		Delete: kind.ServiceDeleted,
+	}
+	c.informers.AddEventHandler(informers.InformerKeyService,
+		GetEventHandlerFuncs(nil, serviceEventTypes, c.msgBroker))
+
+	endpointSliceEventTypes := EventTypes{
+		Add:    kind.EndpointSliceAdded,
+		Update: kind.EndpointSliceUpdated,
+		Delete: kind.EndpointSliceDeleted,
+	}
+	c.informers.AddEventHandler(informers.InformerKeyEndpointSlice,
+		GetEventHandlerFuncs(nil, endpointSliceEventTypes, c.msgBroker))
+}
@@ -81,6 +81,15 @@ func WithKubeClient(kubeClient kubernetes.Interface) InformerCollectionOption {
 	}
 }
 
+// WithServiceInformers sets the Service and EndpointSlice informers for the InformerCollection
+func WithServiceInformers(kubeClient kubernetes.Interface) InformerCollectionOption {
+	return func(ic *InformerCollection) {
+		informerFactory := informers.NewSharedInformerFactory(kubeClient, DefaultKubeEventResyncInterval)
+		ic.informers[InformerKeyService] = informerFactory.Core().V1().Services().Informer()
+		ic.informers[InformerKeyEndpointSlice] = informerFactory.Discovery().V1().EndpointSlices().Informer()
+	}
+}
+
 func (ic *InformerCollection) run(stop <-chan struct{}) error {
 	log.Info().Msg("InformerCollection started")
 	var hasSynced []cache.InformerSynced
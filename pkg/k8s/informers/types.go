@@ -17,6 +17,10 @@ const (
 	InformerKeyPod InformerKey = "Pod"
 	// InformerKeySidecarPod is the InformerKey for a Sidecar Pod informer
 	InformerKeySidecarPod InformerKey = "Sidecar-Pod"
+	// InformerKeyService is the InformerKey for a Service informer
+	InformerKeyService InformerKey = "Service"
+	// InformerKeyEndpointSlice is the InformerKey for a EndpointSlice informer
+	InformerKeyEndpointSlice InformerKey = "EndpointSlice"
 )
 
 const (
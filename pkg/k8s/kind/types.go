@@ -19,6 +19,42 @@ const (
 
 	// SidecarPodUpdated is the type of announcement emitted when we observe an update to a sidecar Pod
 	SidecarPodUpdated Kind = "sidecar-pod-updated"
+
+	// PodAdded is the type of announcement emitted when we observe an addition of a Pod on this node
+	PodAdded Kind = "pod-added"
+
+	// PodDeleted the type of announcement emitted when we observe the deletion of a Pod on this node
+	PodDeleted Kind = "pod-deleted"
+
+	// PodUpdated is the type of announcement emitted when we observe an update to a Pod on this node
+	PodUpdated Kind = "pod-updated"
+
+	// NamespaceAdded is the type of announcement emitted when we observe a Namespace becoming monitored
+	NamespaceAdded Kind = "namespace-added"
+
+	// NamespaceDeleted the type of announcement emitted when we observe a Namespace no longer monitored
+	NamespaceDeleted Kind = "namespace-deleted"
+
+	// NamespaceUpdated is the type of announcement emitted when we observe an update to a monitored Namespace
+	NamespaceUpdated Kind = "namespace-updated"
+
+	// ServiceAdded is the type of announcement emitted when we observe an addition of a Service
+	ServiceAdded Kind = "service-added"
+
+	// ServiceDeleted the type of announcement emitted when we observe the deletion of a Service
+	ServiceDeleted Kind = "service-deleted"
+
+	// ServiceUpdated is the type of announcement emitted when we observe an update to a Service
+	ServiceUpdated Kind = "service-updated"
+
+	// EndpointSliceAdded is the type of announcement emitted when we observe an addition of an EndpointSlice
+	EndpointSliceAdded Kind = "endpointslice-added"
+
+	// EndpointSliceDeleted the type of announcement emitted when we observe the deletion of an EndpointSlice
+	EndpointSliceDeleted Kind = "endpointslice-deleted"
+
+	// EndpointSliceUpdated is the type of announcement emitted when we observe an update to an EndpointSlice
+	EndpointSliceUpdated Kind = "endpointslice-updated"
 )
 
 // Announcement is a struct for messages between various components of FSM signaling a need for a change in Sidecar proxy configuration
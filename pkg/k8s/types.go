@@ -2,6 +2,7 @@ package k8s
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 
 	"github.com/flomesh-io/xnet/pkg/k8s/informers"
 	"github.com/flomesh-io/xnet/pkg/logger"
@@ -34,6 +35,9 @@ type Controller interface {
 
 	IsMonitoredPod(pod string, namespace string) bool
 
+	// GetPod returns k8s pod present in cache
+	GetPod(pod string, namespace string) *corev1.Pod
+
 	// ListAllPods returns all pods
 	ListAllPods() []*corev1.Pod
 
@@ -42,4 +46,10 @@ type Controller interface {
 
 	// ListSidecarPods returns the gateway pods as sidecar.
 	ListSidecarPods() []*corev1.Pod
+
+	// ListServices returns all services
+	ListServices() []*corev1.Service
+
+	// ListServiceEndpointSlices returns the endpoint slices of the service
+	ListServiceEndpointSlices(svc *corev1.Service) []*discoveryv1.EndpointSlice
 }
@@ -47,6 +47,11 @@ func (b *Broker) GetSidecarUpdatePubSub() *pubsub.PubSub {
 	return b.sidecarUpdatePubSub
 }
 
+// GetKubeEventPubSub returns the PubSub instance corresponding to k8s events
+func (b *Broker) GetKubeEventPubSub() *pubsub.PubSub {
+	return b.kubeEventPubSub
+}
+
 // runWorkqueueProcessor starts a goroutine to process events from the workqueue until
 // signalled to stop on the given channel.
 func (b *Broker) runWorkqueueProcessor(stopCh <-chan struct{}) {
@@ -21,7 +21,12 @@ const (
 	NUD_PERMANENT  = 0x80
 )
 
+// Announce announces the addr with the mac, by gratuitous arp for ipv4, or by unsolicited neighbor advertisement for ipv6.
 func Announce(iface, aip string, hwAddr net.HardwareAddr) error {
+	if ip, err := netip.ParseAddr(aip); err == nil && ip.Unmap().Is6() {
+		return Advertise(iface, aip, hwAddr)
+	}
+
 	// Ensure valid network interface
 	ifi, err := net.InterfaceByName(iface)
 	if err != nil {
@@ -0,0 +1,68 @@
+package arp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	ndpHopLimit = 255
+
+	ndpOptTargetLinkLayerAddr = 2
+
+	ndpFlagOverride = 0x20000000
+)
+
+var allNodesMulticast = net.ParseIP("ff02::1")
+
+// Advertise sends an unsolicited neighbor advertisement of the ipv6 addr with the mac to all nodes.
+func Advertise(iface, aip string, hwAddr net.HardwareAddr) error {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(aip)
+	if ip == nil || ip.To4() != nil {
+		return fmt.Errorf("invalid ipv6 addr: %s", aip)
+	}
+
+	c, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	pc := c.IPv6PacketConn()
+	if err = pc.SetMulticastHopLimit(ndpHopLimit); err != nil {
+		return err
+	}
+	if err = pc.SetMulticastInterface(ifi); err != nil {
+		return err
+	}
+
+	// flags(4) + target(16) + target link-layer addr option(8)
+	body := make([]byte, 4, 28)
+	binary.BigEndian.PutUint32(body, ndpFlagOverride)
+	body = append(body, ip.To16()...)
+	body = append(body, ndpOptTargetLinkLayerAddr, 1)
+	body = append(body, hwAddr...)
+
+	msg := icmp.Message{
+		Type: ipv6.ICMPTypeNeighborAdvertisement,
+		Body: &icmp.RawBody{Data: body},
+	}
+	// the checksum is filled by the kernel
+	bs, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+
+	cm := &ipv6.ControlMessage{HopLimit: ndpHopLimit, IfIndex: ifi.Index}
+	_, err = pc.WriteTo(bs, cm, &net.IPAddr{IP: allNodesMulticast, Zone: ifi.Name})
+	return err
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"errors"
 	"fmt"
+	"net"
 
 	"github.com/spf13/cobra"
 
@@ -16,8 +17,10 @@ const aclAddExample = ``
 type aclAddCmd struct {
 	sys
 	sa
+	portRange
 	proto
 
+	cidr string
 	acl  string
 	flag uint8
 	id   uint16
@@ -42,6 +45,8 @@ func newAclAdd() *cobra.Command {
 	f := cmd.Flags()
 	aclAdd.sys.addFlags(f)
 	aclAdd.sa.addFlags(f)
+	aclAdd.portRange.addFlags(f)
+	f.StringVar(&aclAdd.cidr, "cidr", "", "--cidr=10.0.0.0/8, instead of --addr")
 	aclAdd.proto.addFlags(f)
 	f.Uint8Var(&aclAdd.flag, "flag", 0, "--flag=0")
 	f.Uint16Var(&aclAdd.id, "id", 0, "--id=0")
@@ -60,6 +65,32 @@ func (a *aclAddCmd) run() error {
 		return errors.New("missing proto: --proto-tcp/--proto-udp")
 	}
 
+	aclVal := new(maps.AclVal)
+	aclVal.Flag = a.flag
+	aclVal.Id = a.id
+	switch a.acl {
+	case `deny`:
+		aclVal.Acl = uint8(maps.ACL_DENY)
+	case `audit`:
+		aclVal.Acl = uint8(maps.ACL_AUDIT)
+	case `trusted`:
+		aclVal.Acl = uint8(maps.ACL_TRUSTED)
+	default:
+		return fmt.Errorf(`invalid acl:%s`, a.acl)
+	}
+
+	portLo, portHi, isRange, err := a.getPortRange(a.port)
+	if err != nil {
+		return err
+	}
+
+	if len(a.cidr) > 0 {
+		if isRange {
+			return errors.New("--cidr and --port-range are exclusive")
+		}
+		return a.addCIDR(aclVal)
+	}
+
 	if aclKey.Addr[0], aclKey.Addr[1], aclKey.Addr[2], aclKey.Addr[3], _, err = util.IPToInt(a.addr); err != nil {
 		return err
 	}
@@ -76,22 +107,42 @@ func (a *aclAddCmd) run() error {
 		aclKeys = append(aclKeys, aclKey)
 	}
 
-	aclVal := new(maps.AclVal)
-	aclVal.Flag = a.flag
-	aclVal.Id = a.id
-	switch a.acl {
-	case `deny`:
-		aclVal.Acl = uint8(maps.ACL_DENY)
-	case `audit`:
-		aclVal.Acl = uint8(maps.ACL_AUDIT)
-	case `trusted`:
-		aclVal.Acl = uint8(maps.ACL_TRUSTED)
-	default:
-		return fmt.Errorf(`invalid acl:%s`, a.acl)
+	for _, key := range aclKeys {
+		if isRange {
+			err = maps.AddAclRangeEntry(a.sysId(), &key, portLo, portHi, aclVal)
+		} else {
+			err = maps.AddAclEntry(a.sysId(), &key, aclVal)
+		}
+		if err != nil {
+			return err
+		}
 	}
 
-	for _, key := range aclKeys {
-		if err = maps.AddAclEntry(a.sysId(), &key, aclVal); err != nil {
+	return nil
+}
+
+func (a *aclAddCmd) addCIDR(aclVal *maps.AclVal) error {
+	_, cidr, err := net.ParseCIDR(a.cidr)
+	if err != nil {
+		return err
+	}
+
+	aclKey := new(maps.AclCIDRKey)
+	if err = aclKey.SetCIDR(cidr); err != nil {
+		return err
+	}
+	aclKey.Port = util.HostToNetShort(a.port)
+
+	if a.tcp {
+		aclKey.Proto = uint8(maps.IPPROTO_TCP)
+		if err = maps.AddAclCIDREntry(a.sysId(), aclKey, aclVal); err != nil {
+			return err
+		}
+	}
+
+	if a.udp {
+		aclKey.Proto = uint8(maps.IPPROTO_UDP)
+		if err = maps.AddAclCIDREntry(a.sysId(), aclKey, aclVal); err != nil {
 			return err
 		}
 	}
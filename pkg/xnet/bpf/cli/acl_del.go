@@ -2,6 +2,7 @@ package cli
 
 import (
 	"errors"
+	"net"
 
 	"github.com/spf13/cobra"
 
@@ -15,7 +16,10 @@ const aclDelExample = ``
 type aclDelCmd struct {
 	sys
 	sa
+	portRange
 	proto
+
+	cidr string
 }
 
 func newAclDel() *cobra.Command {
@@ -37,6 +41,8 @@ func newAclDel() *cobra.Command {
 	f := cmd.Flags()
 	aclDel.sys.addFlags(f)
 	aclDel.sa.addFlags(f)
+	aclDel.portRange.addFlags(f)
+	f.StringVar(&aclDel.cidr, "cidr", "", "--cidr=10.0.0.0/8, instead of --addr")
 	aclDel.proto.addFlags(f)
 
 	return cmd
@@ -51,6 +57,18 @@ func (a *aclDelCmd) run() error {
 		return errors.New("missing proto: --proto-tcp/--proto-udp")
 	}
 
+	portLo, portHi, isRange, err := a.getPortRange(a.port)
+	if err != nil {
+		return err
+	}
+
+	if len(a.cidr) > 0 {
+		if isRange {
+			return errors.New("--cidr and --port-range are exclusive")
+		}
+		return a.delCIDR()
+	}
+
 	if aclKey.Addr[0], aclKey.Addr[1], aclKey.Addr[2], aclKey.Addr[3], _, err = util.IPToInt(a.addr); err != nil {
 		return err
 	}
@@ -59,14 +77,50 @@ func (a *aclDelCmd) run() error {
 
 	if a.tcp {
 		aclKey.Proto = uint8(maps.IPPROTO_TCP)
-		if err = maps.DelAclEntry(a.sysId(), aclKey); err != nil {
+		if err = a.delEntry(aclKey, portLo, portHi, isRange); err != nil {
+			return err
+		}
+	}
+
+	if a.udp {
+		aclKey.Proto = uint8(maps.IPPROTO_UDP)
+		if err = a.delEntry(aclKey, portLo, portHi, isRange); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *aclDelCmd) delEntry(aclKey *maps.AclKey, portLo, portHi uint16, isRange bool) error {
+	if isRange {
+		return maps.DelAclRangeEntry(a.sysId(), aclKey, portLo, portHi)
+	}
+	return maps.DelAclEntry(a.sysId(), aclKey)
+}
+
+func (a *aclDelCmd) delCIDR() error {
+	_, cidr, err := net.ParseCIDR(a.cidr)
+	if err != nil {
+		return err
+	}
+
+	aclKey := new(maps.AclCIDRKey)
+	if err = aclKey.SetCIDR(cidr); err != nil {
+		return err
+	}
+	aclKey.Port = util.HostToNetShort(a.port)
+
+	if a.tcp {
+		aclKey.Proto = uint8(maps.IPPROTO_TCP)
+		if err = maps.DelAclCIDREntry(a.sysId(), aclKey); err != nil {
 			return err
 		}
 	}
 
 	if a.udp {
 		aclKey.Proto = uint8(maps.IPPROTO_UDP)
-		if err = maps.DelAclEntry(a.sysId(), aclKey); err != nil {
+		if err = maps.DelAclCIDREntry(a.sysId(), aclKey); err != nil {
 			return err
 		}
 	}
@@ -26,7 +26,7 @@ func newArpAnnounce() *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:     "announce",
-		Short:   "announce arp",
+		Short:   "announce arp, or ndp for ipv6",
 		Long:    arpAnnounceDescription,
 		Aliases: []string{"an"},
 		Args:    cobra.MinimumNArgs(0),
@@ -45,6 +45,7 @@ type configSetCmd struct {
 	traceFlowOn              int8
 	traceByIpOn              int8
 	traceByPortOn            int8
+	rejectOn                 int8
 
 	debugOn bool
 	optOn   bool
@@ -101,6 +102,7 @@ func newConfigSet() *cobra.Command {
 	f.Int8Var(&configSet.traceFlowOn, "trace_flow_on", -1, "--trace_flow_on=0/1")
 	f.Int8Var(&configSet.traceByIpOn, "trace_by_ip_on", -1, "--trace_by_ip_on=0/1")
 	f.Int8Var(&configSet.traceByPortOn, "trace_by_port_on", -1, "--trace_by_port_on=0/1")
+	f.Int8Var(&configSet.rejectOn, "reject_on", -1, "--reject_on=0/1")
 
 	f.BoolVar(&configSet.debugOn, "debug-on", false, "--debug-on")
 	f.BoolVar(&configSet.optOn, "opt-on", false, "--opt-on")
@@ -362,6 +364,12 @@ func (a *configSetCmd) setDeny(cfgVal *maps.CfgVal) {
 		} else if a.denyAll == 0 {
 			proto.Clear(maps.CfgFlagOffsetDenyAll)
 		}
+
+		if a.rejectOn == 1 {
+			proto.Set(maps.CfgFlagOffsetRejectOn)
+		} else if a.rejectOn == 0 {
+			proto.Clear(maps.CfgFlagOffsetRejectOn)
+		}
 	}
 }
 
@@ -68,6 +68,26 @@ func (c *sa) addPortFlag(f *flag.FlagSet) {
 	f.Uint16Var(&c.port, "port", 0, "--port=0")
 }
 
+type portRange struct {
+	portRange string
+}
+
+func (c *portRange) addFlags(f *flag.FlagSet) {
+	f.StringVar(&c.portRange, "port-range", "", "--port-range=30000-30100, instead of --port")
+}
+
+// getPortRange returns the ports of the range, or false if no range is set
+func (c *portRange) getPortRange(port uint16) (uint16, uint16, bool, error) {
+	if len(c.portRange) == 0 {
+		return 0, 0, false, nil
+	}
+	if port != 0 {
+		return 0, 0, false, fmt.Errorf("--port and --port-range are exclusive")
+	}
+	lo, hi, err := maps.ParsePortRange(c.portRange)
+	return lo, hi, err == nil, err
+}
+
 type ep struct {
 	addr   net.IP
 	port   uint16
@@ -75,6 +95,7 @@ type ep struct {
 	ofi    uint32
 	oflags uint32
 	omac   string
+	weight uint16
 	active bool
 }
 
@@ -88,6 +109,7 @@ func (c *ep) addFlags(f *flag.FlagSet, mac, active bool) {
 		f.StringVar(&c.omac, "ep-omac", "", "--ep-omac=00:00:00:00:00:00")
 	}
 	if active {
+		f.Uint16Var(&c.weight, "ep-weight", maps.NatEpDefaultWeight, "--ep-weight=1")
 		f.BoolVar(&c.active, "active", true, "--active=true/false")
 	}
 }
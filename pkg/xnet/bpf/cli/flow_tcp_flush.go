@@ -1,11 +1,17 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/spf13/cobra"
 
-	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+	"github.com/flomesh-io/xnet/pkg/xnet/cni"
 )
 
 const tcpFlowFlushDescription = ``
@@ -16,6 +22,7 @@ type tcpFlowFlushCmd struct {
 
 	idleSeconds int
 	batchSize   int
+	unixSock    string
 }
 
 func newTCPFlowFlush() *cobra.Command {
@@ -23,7 +30,7 @@ func newTCPFlowFlush() *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:     "flush",
-		Short:   "flush idle tcp flows",
+		Short:   "flush idle tcp flows through xctr",
 		Long:    tcpFlowFlushDescription,
 		Aliases: []string{"f", "fl"},
 		Args:    cobra.MinimumNArgs(0),
@@ -38,15 +45,36 @@ func newTCPFlowFlush() *cobra.Command {
 	flowFlush.sys.addFlags(f)
 	f.IntVar(&flowFlush.idleSeconds, "idle-seconds", 3600, "--idle-seconds=3600")
 	f.IntVar(&flowFlush.batchSize, "batch-size", 1024, "--batch-size=1024")
+	f.StringVar(&flowFlush.unixSock, "unix-sock", "/host/run/.xnet.sock", "--unix-sock=unix.sock")
 
 	return cmd
 }
 
+// run asks xctr to flush, as the conns of the deleted flows are released by xctr only
 func (a *tcpFlowFlushCmd) run() error {
-	items, err := maps.FlushIdleTCPFlowEntries(a.sysId(), a.idleSeconds, a.batchSize)
+	httpc := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", a.unixSock)
+			},
+		},
+	}
+	query := url.Values{}
+	query.Set("sys", strconv.Itoa(int(a.sysId())))
+	query.Set("idle_seconds", strconv.Itoa(a.idleSeconds))
+	query.Set("batch_size", strconv.Itoa(a.batchSize))
+	r, err := httpc.Post("http://"+cni.PluginName+cni.FlushTCPFlowsURI+"?"+query.Encode(), "", nil)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	bs, err := io.ReadAll(r.Body)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("flush %d items.\n", items)
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("fail to flush idle tcp flows: %s", string(bs))
+	}
+	fmt.Printf("flush %s items.\n", string(bs))
 	return nil
 }
@@ -28,6 +28,7 @@ func NewNatCmd() *cobra.Command {
 
 type nat struct {
 	sa
+	portRange
 	proto
 	tc
 	ep
@@ -40,6 +41,12 @@ func (c *nat) getKeys() ([]maps.NatKey, error) {
 		return nil, err
 	}
 	natKey.Dport = util.HostToNetShort(c.sa.port)
+	if portLo, portHi, isRange, rangeErr := c.getPortRange(c.sa.port); rangeErr != nil {
+		return nil, rangeErr
+	} else if isRange {
+		natKey.Dport = util.HostToNetShort(portLo)
+		natKey.DportHi = util.HostToNetShort(portHi)
+	}
 
 	if !c.tcp && !c.udp {
 		return nil, errors.New("missing proto: --proto-tcp/--proto-udp")
@@ -2,11 +2,13 @@ package cli
 
 import (
 	"fmt"
+	"math"
 	"net"
 
 	"github.com/spf13/cobra"
 
 	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+	"github.com/flomesh-io/xnet/pkg/xnet/neigh"
 )
 
 const natAddDescription = ``
@@ -15,6 +17,12 @@ const natAddExample = ``
 type natAddCmd struct {
 	sys
 	nat
+
+	mode            string
+	affinity        string
+	affinityTimeout uint32
+	maxConns        int64
+	epMaxConns      int64
 }
 
 func newNatAdd() *cobra.Command {
@@ -36,9 +44,15 @@ func newNatAdd() *cobra.Command {
 	f := cmd.Flags()
 	natAdd.sys.addFlags(f)
 	natAdd.sa.addFlags(f)
+	natAdd.portRange.addFlags(f)
 	natAdd.proto.addFlags(f)
 	natAdd.tc.addFlags(f)
 	natAdd.ep.addFlags(f, true, true)
+	f.StringVar(&natAdd.mode, "mode", "", "--mode=rr/maglev")
+	f.StringVar(&natAdd.affinity, "affinity", "", "--affinity=none/client-ip")
+	f.Uint32Var(&natAdd.affinityTimeout, "affinity-timeout", maps.NatAffinityDefaultTimeout, "--affinity-timeout=10800")
+	f.Int64Var(&natAdd.maxConns, "max-conns", -1, "--max-conns=0, max concurrent tcp conns of all eps, 0 for unlimited")
+	f.Int64Var(&natAdd.epMaxConns, "ep-max-conns", -1, "--ep-max-conns=0, max concurrent tcp conns of the ep, 0 for unlimited")
 
 	return cmd
 }
@@ -50,24 +64,73 @@ func (a *natAddCmd) run() error {
 		if a.ep.addr.IsUnspecified() {
 			return fmt.Errorf(`invalid ep addr: %s`, a.ep.addr)
 		}
-		if a.ep.port == 0 {
+		// eps of a port range keep the dport without a port
+		if a.ep.port == 0 && len(a.portRange.portRange) == 0 {
 			return fmt.Errorf(`invalid ep port: %d`, a.ep.port)
 		}
-		mac, macErr := net.ParseMAC(a.ep.mac)
-		if macErr != nil {
-			return fmt.Errorf(`invalid ep MAC address: %s`, a.ep.mac)
+		if a.ep.weight == 0 {
+			return fmt.Errorf(`invalid ep weight: %d`, a.ep.weight)
+		}
+		if a.maxConns > math.MaxUint32 {
+			return fmt.Errorf(`invalid max conns: %d`, a.maxConns)
+		}
+		if a.epMaxConns > math.MaxUint32 {
+			return fmt.Errorf(`invalid ep max conns: %d`, a.epMaxConns)
+		}
+		mode := maps.NatModeMax
+		if len(a.mode) > 0 {
+			if mode, err = maps.ParseNatMode(a.mode); err != nil {
+				return err
+			}
+		}
+		affinity := maps.NatAffinityMax
+		if len(a.affinity) > 0 {
+			if affinity, err = maps.ParseNatAffinity(a.affinity); err != nil {
+				return err
+			}
 		}
-		omac, omacErr := net.ParseMAC(a.ep.omac)
-		if omacErr != nil {
-			if len(a.ep.omac) > 0 {
+		ofi := a.ep.ofi
+		var mac, omac net.HardwareAddr
+		if len(a.ep.mac) == 0 {
+			// resolve the egress of the ep by the routing and neighbour tables
+			hop, hopErr := neigh.Resolve(a.ep.addr)
+			if hopErr != nil {
+				return fmt.Errorf(`fail to resolve ep: %s %s`, a.ep.addr, hopErr.Error())
+			}
+			mac = hop.Rmac
+			omac = hop.Omac
+			if ofi == 0 {
+				ofi = hop.Ofi
+			}
+		} else {
+			var macErr error
+			if mac, macErr = net.ParseMAC(a.ep.mac); macErr != nil {
+				return fmt.Errorf(`invalid ep MAC address: %s`, a.ep.mac)
+			}
+		}
+		if len(a.ep.omac) > 0 {
+			var omacErr error
+			if omac, omacErr = net.ParseMAC(a.ep.omac); omacErr != nil {
 				return fmt.Errorf(`invalid ep OMAC address: %s`, a.ep.omac)
 			}
 		}
 		for _, natKey := range natKeys {
 			natVal, _ := maps.GetNatEntry(a.sysId(), &natKey)
-			if _, err = natVal.AddEp(a.ep.addr, a.ep.port, mac, a.ep.ofi, a.ep.oflags, omac, a.active); err != nil {
+			if mode < maps.NatModeMax {
+				natVal.Mode = uint8(mode)
+			}
+			if affinity < maps.NatAffinityMax {
+				natVal.SetAffinity(affinity, a.affinityTimeout)
+			}
+			if a.maxConns >= 0 {
+				natVal.SetMaxConns(uint32(a.maxConns))
+			}
+			if _, err = natVal.AddEp(a.ep.addr, a.ep.port, mac, ofi, a.ep.oflags, omac, a.ep.weight, a.active); err != nil {
 				fmt.Printf(`add ep addr: %s port: %d fail: %s\n`, a.ep.addr, a.ep.port, err.Error())
 			} else {
+				if a.epMaxConns >= 0 {
+					_, _ = natVal.SetEpMaxConns(a.ep.addr, a.ep.port, uint32(a.epMaxConns))
+				}
 				if err = maps.AddNatEntry(a.sysId(), &natKey, natVal); err != nil {
 					fmt.Printf(`add nat: {"key":%s,"value":%s} fail: %s`, natKey.String(), natVal.String(), err.Error())
 				}
@@ -35,6 +35,7 @@ func newNatDel() *cobra.Command {
 	f := cmd.Flags()
 	natDel.sys.addFlags(f)
 	natDel.sa.addFlags(f)
+	natDel.portRange.addFlags(f)
 	natDel.proto.addFlags(f)
 	natDel.tc.addFlags(f)
 	natDel.ep.addFlags(f, false, false)
@@ -46,7 +47,7 @@ func (a *natDelCmd) run() error {
 	if natKeys, err := a.getKeys(); err != nil {
 		return err
 	} else {
-		if a.ep.addr.IsUnspecified() || a.ep.port == 0 {
+		if a.ep.addr.IsUnspecified() || (a.ep.port == 0 && len(a.portRange.portRange) == 0) {
 			for _, natKey := range natKeys {
 				if err = maps.DelNatEntry(a.sysId(), &natKey); err != nil {
 					fmt.Println(err.Error())
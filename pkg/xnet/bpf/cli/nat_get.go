@@ -35,6 +35,7 @@ func newNatGet() *cobra.Command {
 	f := cmd.Flags()
 	natGet.sys.addFlags(f)
 	natGet.sa.addFlags(f)
+	natGet.portRange.addFlags(f)
 	natGet.proto.addFlags(f)
 	natGet.tc.addFlags(f)
 
@@ -54,7 +55,11 @@ func (a *natGetCmd) run() error {
 				fmt.Println(`,`)
 			}
 			if natVal, err := maps.GetNatEntry(a.sysId(), &natKey); err == nil {
-				fmt.Printf(`{"key":%s,"value":%s}`, natKey.String(), natVal.String())
+				if conns, epConns, connsErr := maps.GetNatConns(&natKey, natVal); connsErr == nil {
+					fmt.Printf(`{"key":%s,"value":%s,"conns":%s}`, natKey.String(), natVal.String(), natVal.ConnsString(conns, epConns))
+				} else {
+					fmt.Printf(`{"key":%s,"value":%s,"conns":"%s"}`, natKey.String(), natVal.String(), connsErr.Error())
+				}
 			} else {
 				fmt.Printf(`{"key":%s,"value":"%s"}`, natKey.String(), err.Error())
 			}
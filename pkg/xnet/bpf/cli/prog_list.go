@@ -10,6 +10,7 @@ const progListDescription = ``
 const progListExample = ``
 
 type progListCmd struct {
+	maps bool
 }
 
 func newProgList() *cobra.Command {
@@ -27,10 +28,18 @@ func newProgList() *cobra.Command {
 		Example: progListExample,
 	}
 
+	//add flags
+	f := cmd.Flags()
+	f.BoolVar(&progList.maps, "maps", false, "--maps")
+
 	return cmd
 }
 
 func (a *progListCmd) run() error {
+	if a.maps {
+		maps.ShowMapCapacities()
+		return nil
+	}
 	maps.ShowProgEntries()
 	return nil
 }
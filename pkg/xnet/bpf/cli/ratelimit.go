@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+)
+
+const rateLimitDescription = ``
+
+func NewRateLimitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ratelimit",
+		Short: "ratelimit",
+		Long:  rateLimitDescription,
+		Args:  cobra.NoArgs,
+	}
+	cmd.AddCommand(newRateLimitList())
+	cmd.AddCommand(newRateLimitAdd())
+	cmd.AddCommand(newRateLimitDel())
+
+	return cmd
+}
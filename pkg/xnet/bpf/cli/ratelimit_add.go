@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+)
+
+const rateLimitAddDescription = ``
+const rateLimitAddExample = ``
+
+type rateLimitAddCmd struct {
+	sys
+	sa
+
+	rate  uint32
+	burst uint32
+}
+
+func newRateLimitAdd() *cobra.Command {
+	rateLimitAdd := &rateLimitAddCmd{}
+
+	cmd := &cobra.Command{
+		Use:     "add",
+		Short:   "add",
+		Long:    rateLimitAddDescription,
+		Aliases: []string{"a"},
+		Args:    cobra.MinimumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rateLimitAdd.run()
+		},
+		Example: rateLimitAddExample,
+	}
+
+	//add flags
+	f := cmd.Flags()
+	rateLimitAdd.sys.addFlags(f)
+	rateLimitAdd.sa.addAddrFlag(f)
+	f.Uint32Var(&rateLimitAdd.rate, "rate", 0, "--rate=100, new flows per second of the source addr")
+	f.Uint32Var(&rateLimitAdd.burst, "burst", 0, "--burst=0, defaults to rate")
+
+	return cmd
+}
+
+func (a *rateLimitAddCmd) run() error {
+	return maps.SetRateLimit(a.sysId(), a.addr, a.rate, a.burst)
+}
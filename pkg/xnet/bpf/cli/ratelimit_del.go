@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+)
+
+const rateLimitDelDescription = ``
+const rateLimitDelExample = ``
+
+type rateLimitDelCmd struct {
+	sys
+	sa
+}
+
+func newRateLimitDel() *cobra.Command {
+	rateLimitDel := &rateLimitDelCmd{}
+
+	cmd := &cobra.Command{
+		Use:     "del",
+		Short:   "del",
+		Long:    rateLimitDelDescription,
+		Aliases: []string{"d"},
+		Args:    cobra.MinimumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rateLimitDel.run()
+		},
+		Example: rateLimitDelExample,
+	}
+
+	//add flags
+	f := cmd.Flags()
+	rateLimitDel.sys.addFlags(f)
+	rateLimitDel.sa.addAddrFlag(f)
+
+	return cmd
+}
+
+func (a *rateLimitDelCmd) run() error {
+	return maps.DelRateLimit(a.sysId(), a.addr)
+}
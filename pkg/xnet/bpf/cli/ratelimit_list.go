@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+)
+
+const rateLimitListDescription = ``
+const rateLimitListExample = ``
+
+type rateLimitListCmd struct {
+}
+
+func newRateLimitList() *cobra.Command {
+	rateLimitList := &rateLimitListCmd{}
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "list",
+		Long:    rateLimitListDescription,
+		Aliases: []string{"l", "ls"},
+		Args:    cobra.MinimumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rateLimitList.run()
+		},
+		Example: rateLimitListExample,
+	}
+
+	return cmd
+}
+
+func (a *rateLimitListCmd) run() error {
+	maps.ShowRateLimits()
+	return nil
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flomesh-io/xnet/pkg/signals"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+)
+
+const statsDescription = ``
+const statsExample = ``
+
+type statsCmd struct {
+	sys
+
+	watch    bool
+	interval int
+}
+
+func NewStatsCmd() *cobra.Command {
+	stats := &statsCmd{}
+
+	cmd := &cobra.Command{
+		Use:     "stats",
+		Short:   "show datapath stats",
+		Long:    statsDescription,
+		Aliases: []string{"st"},
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return stats.run()
+		},
+		Example: statsExample,
+	}
+	cmd.AddCommand(newStatsReset())
+
+	//add flags
+	f := cmd.Flags()
+	stats.sys.addFlags(f)
+	f.BoolVar(&stats.watch, "watch", false, "--watch")
+	f.IntVar(&stats.interval, "interval", 1, "--interval=1")
+
+	return cmd
+}
+
+func (a *statsCmd) run() error {
+	if a.interval <= 0 {
+		return fmt.Errorf(`invalid interval: %d`, a.interval)
+	}
+
+	prevStats, err := a.getStats()
+	if err != nil {
+		return err
+	}
+	if !a.watch {
+		a.showStats(prevStats, nil, 0)
+		return nil
+	}
+
+	_, cancel := context.WithCancel(context.Background())
+	stop := signals.RegisterExitHandlers(cancel)
+
+	interval := time.Second * time.Duration(a.interval)
+	scheduleTimer := time.NewTimer(interval)
+	defer scheduleTimer.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-scheduleTimer.C:
+			currStats, statsErr := a.getStats()
+			if statsErr != nil {
+				return statsErr
+			}
+			a.showStats(currStats, prevStats, a.interval)
+			prevStats = currStats
+			scheduleTimer.Reset(interval)
+		}
+	}
+}
+
+func (a *statsCmd) getStats() (map[maps.StatKey]maps.StatVal, error) {
+	stats, err := maps.GetStats()
+	if err != nil {
+		return nil, err
+	}
+	if len(a.sys.sys) > 0 {
+		sysId := uint32(a.sysId())
+		for statKey := range stats {
+			if statKey.Sys != sysId {
+				delete(stats, statKey)
+			}
+		}
+	}
+	return stats, nil
+}
+
+func (a *statsCmd) showStats(currStats, prevStats map[maps.StatKey]maps.StatVal, seconds int) {
+	statKeys := make([]maps.StatKey, 0, len(currStats))
+	for statKey := range currStats {
+		statKeys = append(statKeys, statKey)
+	}
+	sort.Slice(statKeys, func(i, j int) bool {
+		if statKeys[i].Sys != statKeys[j].Sys {
+			return statKeys[i].Sys < statKeys[j].Sys
+		}
+		return statKeys[i].Reason < statKeys[j].Reason
+	})
+
+	var sb strings.Builder
+	sb.WriteString("[\n")
+	for idx, statKey := range statKeys {
+		if idx > 0 {
+			sb.WriteString(",\n")
+		}
+		statVal := currStats[statKey]
+		sb.WriteString(fmt.Sprintf(`{"sys":"%s","reason":"%s","pkts":%d,"bytes":%d`,
+			maps.SysName(maps.SysID(statKey.Sys)), maps.StatReason(statKey.Reason), statVal.Pkts, statVal.Bytes))
+		if prevStats != nil && seconds > 0 {
+			prevVal := prevStats[statKey]
+			sb.WriteString(fmt.Sprintf(`,"pps":%d,"bps":%d`,
+				statDelta(statVal.Pkts, prevVal.Pkts)/uint64(seconds), statDelta(statVal.Bytes, prevVal.Bytes)*8/uint64(seconds)))
+		}
+		sb.WriteString(`}`)
+	}
+	sb.WriteString("\n]")
+	fmt.Println(sb.String())
+}
+
+// statDelta restarts the baseline from zero when the counter went backwards, e.g. after a stats reset.
+func statDelta(curr, prev uint64) uint64 {
+	if curr < prev {
+		return curr
+	}
+	return curr - prev
+}
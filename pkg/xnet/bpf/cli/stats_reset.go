@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+)
+
+const statsResetDescription = ``
+const statsResetExample = ``
+
+type statsResetCmd struct {
+	sys
+}
+
+func newStatsReset() *cobra.Command {
+	statsReset := &statsResetCmd{}
+
+	cmd := &cobra.Command{
+		Use:     "reset",
+		Short:   "reset datapath stats",
+		Long:    statsResetDescription,
+		Aliases: []string{"r"},
+		Args:    cobra.MinimumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return statsReset.run()
+		},
+		Example: statsResetExample,
+	}
+
+	//add flags
+	f := cmd.Flags()
+	statsReset.sys.addFlags(f)
+
+	return cmd
+}
+
+func (a *statsResetCmd) run() error {
+	return maps.ResetStats(a.sysId())
+}
@@ -16,6 +16,7 @@ func NewTraceCmd() *cobra.Command {
 	}
 	cmd.AddCommand(NewTraceIPCmd())
 	cmd.AddCommand(NewTracePortCmd())
+	cmd.AddCommand(newTraceWatch())
 
 	return cmd
 }
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flomesh-io/xnet/pkg/signals"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+	"github.com/flomesh-io/xnet/pkg/xnet/util"
+)
+
+const traceWatchDescription = ``
+const traceWatchExample = ``
+
+type traceWatchCmd struct {
+	sys
+	sa
+	proto
+}
+
+func newTraceWatch() *cobra.Command {
+	traceWatch := &traceWatchCmd{}
+
+	cmd := &cobra.Command{
+		Use:     "watch",
+		Short:   "watch trace events",
+		Long:    traceWatchDescription,
+		Aliases: []string{"w"},
+		Args:    cobra.MinimumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return traceWatch.run()
+		},
+		Example: traceWatchExample,
+	}
+
+	//add flags
+	f := cmd.Flags()
+	traceWatch.sys.addFlags(f)
+	traceWatch.sa.addFlags(f)
+	traceWatch.proto.addFlags(f)
+
+	return cmd
+}
+
+func (a *traceWatchCmd) run() error {
+	var addr [4]uint32
+	if !a.addr.IsUnspecified() {
+		var err error
+		if addr[0], addr[1], addr[2], addr[3], _, err = util.IPToInt(a.addr); err != nil {
+			return err
+		}
+	}
+	port := util.HostToNetShort(a.port)
+
+	_, cancel := context.WithCancel(context.Background())
+	stop := signals.RegisterExitHandlers(cancel)
+
+	return maps.WatchTraceEvents(stop, func(evt *maps.TraceEvent) {
+		if len(a.sys.sys) > 0 && evt.Flow.Sys != uint32(a.sysId()) {
+			return
+		}
+		if !a.addr.IsUnspecified() && evt.Flow.Saddr != addr && evt.Flow.Daddr != addr {
+			return
+		}
+		if a.port > 0 && evt.Flow.Sport != port && evt.Flow.Dport != port {
+			return
+		}
+		if a.tcp != a.udp {
+			if a.tcp && evt.Flow.Proto != uint8(maps.IPPROTO_TCP) {
+				return
+			}
+			if a.udp && evt.Flow.Proto != uint8(maps.IPPROTO_UDP) {
+				return
+			}
+		}
+		fmt.Println(evt.String())
+	})
+}
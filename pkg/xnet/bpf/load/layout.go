@@ -0,0 +1,231 @@
+package load
+
+import (
+	"fmt"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+)
+
+const (
+	natMaxEndpoints = 128
+	natMaglevSize   = 16381
+	natWrrSize      = 4096
+)
+
+// field is a member of a map key or value, copied by name between the layouts of prog versions.
+// Fields missing from the old layout are zero, the spin locks are never copied.
+type field struct {
+	name string
+	off  int
+	size int
+}
+
+// layout is the key and value of a map in one prog version, told apart by their sizes.
+type layout struct {
+	keySize   int
+	valueSize int
+	key       []field
+	value     []field
+}
+
+// layouts lists the layouts of the maps whose key or value changed, oldest first,
+// the last one is the layout of the current prog.
+var layouts = map[string][]layout{
+	bpf.FSM_MAP_NAME_NAT: {
+		// baseline
+		natLayout(false, 5128, 8, 40, natOpFields(false, false, false), natEpFields(false, false, false)),
+		// mode
+		natLayout(false, 5132, 12, 40, natOpFields(true, false, false), natEpFields(false, false, false)),
+		// ep weight
+		natLayout(false, 5644, 12, 44, natOpFields(true, false, false), natEpFields(true, false, false)),
+		// affinity
+		natLayout(false, 5648, 16, 44, natOpFields(true, true, false), natEpFields(true, false, false)),
+		// ep drain deadline
+		natLayout(false, 6160, 16, 48, natOpFields(true, true, false), natEpFields(true, true, false)),
+		// dport range
+		natLayout(true, 6160, 16, 48, natOpFields(true, true, false), natEpFields(true, true, false)),
+		// max conns
+		natLayout(true, 6676, 20, 52, natOpFields(true, true, true), natEpFields(true, true, true)),
+	},
+	bpf.FSM_MAP_NAME_MAGLEV: {
+		{keySize: 25, valueSize: natMaglevSize, key: natKeyFields(false), value: []field{{"eps", 0, natMaglevSize}}},
+		{keySize: 27, valueSize: natMaglevSize, key: natKeyFields(true), value: []field{{"eps", 0, natMaglevSize}}},
+	},
+	bpf.FSM_MAP_NAME_WRR: {
+		{keySize: 25, valueSize: 2 + natWrrSize, key: natKeyFields(false), value: natWrrFields()},
+		{keySize: 27, valueSize: 2 + natWrrSize, key: natKeyFields(true), value: natWrrFields()},
+	},
+	bpf.FSM_MAP_NAME_AFFINITY: {
+		{keySize: 41, valueSize: 32, key: natAffinityKeyFields(false), value: natAffinityFields()},
+		{keySize: 43, valueSize: 32, key: natAffinityKeyFields(true), value: natAffinityFields()},
+	},
+	bpf.FSM_MAP_NAME_TCP_FLOW: {
+		{keySize: 42, valueSize: 112, key: []field{{"flow", 0, 42}}, value: flowOpFields(false)},
+		// conn counts
+		{keySize: 42, valueSize: 160, key: []field{{"flow", 0, 42}}, value: flowOpFields(true)},
+	},
+	bpf.FSM_MAP_NAME_UDP_FLOW: {
+		{keySize: 42, valueSize: 112, key: []field{{"flow", 0, 42}}, value: flowOpFields(false)},
+		// conn counts
+		{keySize: 42, valueSize: 160, key: []field{{"flow", 0, 42}}, value: flowOpFields(true)},
+	},
+}
+
+// scratchMaps only pass a packet between tail calls, they are not migrated.
+var scratchMaps = map[string]bool{
+	bpf.FSM_MAP_NAME_PKT:     true,
+	bpf.FSM_MAP_NAME_FLOW_OP: true,
+}
+
+func natKeyFields(dportHi bool) []field {
+	fields := []field{
+		{"sys", 0, 4},
+		{"daddr", 4, 16},
+		{"dport", 20, 2},
+		{"proto", 22, 1},
+		{"v6", 23, 1},
+		{"tc_dir", 24, 1},
+	}
+	if dportHi {
+		fields = append(fields, field{"dport_hi", 25, 2})
+	}
+	return fields
+}
+
+func natKeySize(dportHi bool) int {
+	if dportHi {
+		return 27
+	}
+	return 25
+}
+
+func natOpFields(mode, affinity, maxConns bool) []field {
+	fields := []field{
+		{"ep_sel", 4, 2},
+		{"ep_cnt", 6, 2},
+	}
+	if mode {
+		fields = append(fields, field{"mode", 8, 1})
+	}
+	if affinity {
+		fields = append(fields, field{"affinity", 9, 1}, field{"affinity_timeout", 12, 4})
+	}
+	if maxConns {
+		fields = append(fields, field{"max_conns", 16, 4})
+	}
+	return fields
+}
+
+func natEpFields(weight, drainDeadline, maxConns bool) []field {
+	fields := []field{
+		{"raddr", 0, 16},
+		{"rport", 16, 2},
+		{"rmac", 18, 6},
+		{"ofi", 24, 4},
+		{"oflags", 28, 4},
+		{"omac", 32, 6},
+		{"omac_set", 38, 1},
+		{"active", 39, 1},
+	}
+	if weight {
+		fields = append(fields, field{"weight", 40, 2})
+	}
+	if drainDeadline {
+		fields = append(fields, field{"drain_deadline", 44, 4})
+	}
+	if maxConns {
+		fields = append(fields, field{"max_conns", 48, 4})
+	}
+	return fields
+}
+
+// natLayout expands the eps array of nat_op_t at epsOff into the fields of every ep.
+func natLayout(dportHi bool, valueSize, epsOff, epSize int, opFields, epFields []field) layout {
+	value := opFields
+	for idx := 0; idx < natMaxEndpoints; idx++ {
+		for _, f := range epFields {
+			value = append(value, field{fmt.Sprintf("eps[%d].%s", idx, f.name), epsOff + idx*epSize + f.off, f.size})
+		}
+	}
+	return layout{keySize: natKeySize(dportHi), valueSize: valueSize, key: natKeyFields(dportHi), value: value}
+}
+
+func natWrrFields() []field {
+	return []field{
+		{"cnt", 0, 2},
+		{"eps", 2, natWrrSize},
+	}
+}
+
+func natAffinityKeyFields(dportHi bool) []field {
+	return append(natKeyFields(dportHi), field{"caddr", natKeySize(dportHi), 16})
+}
+
+func natAffinityFields() []field {
+	return []field{
+		{"atime", 0, 8},
+		{"raddr", 8, 16},
+		{"rport", 24, 2},
+		{"ep_sel", 26, 2},
+	}
+}
+
+func flowOpFields(conn bool) []field {
+	fields := []field{
+		{"flow_dir", 4, 1},
+		{"fin", 5, 1},
+		{"nfs", 6, 2},
+		{"atime", 8, 8},
+		{"xnat", 16, 56},
+		{"trans", 72, 36},
+		{"do_trans", 108, 1},
+	}
+	if conn {
+		fields = append(fields, field{"conn_on", 109, 1}, field{"conn", 110, 46})
+	}
+	return fields
+}
+
+// findLayout returns the layout of the map with the key and value sizes.
+func findLayout(mapName string, keySize, valueSize int) (*layout, bool) {
+	for idx := range layouts[mapName] {
+		if l := &layouts[mapName][idx]; l.keySize == keySize && l.valueSize == valueSize {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// fieldCopy copies a field from its offset in the old layout to the one in the new layout.
+type fieldCopy struct {
+	src  int
+	dst  int
+	size int
+}
+
+// planCopies matches the fields of the new layout with the ones of the old layout by name.
+func planCopies(oldFields, newFields []field) ([]fieldCopy, error) {
+	oldByName := make(map[string]field, len(oldFields))
+	for _, f := range oldFields {
+		oldByName[f.name] = f
+	}
+	var copies []fieldCopy
+	for _, nf := range newFields {
+		of, exists := oldByName[nf.name]
+		if !exists {
+			continue
+		}
+		if of.size != nf.size {
+			return nil, fmt.Errorf("field %s size changed from %d to %d", nf.name, of.size, nf.size)
+		}
+		copies = append(copies, fieldCopy{src: of.off, dst: nf.off, size: nf.size})
+	}
+	return copies, nil
+}
+
+func applyCopies(copies []fieldCopy, src, dst []byte) {
+	clear(dst)
+	for _, c := range copies {
+		copy(dst[c.dst:c.dst+c.size], src[c.src:c.src+c.size])
+	}
+}
@@ -0,0 +1,196 @@
+package load
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+)
+
+func TestLayoutsFitTheirSizes(t *testing.T) {
+	for mapName, mapLayouts := range layouts {
+		for idx, l := range mapLayouts {
+			checkFields(t, mapName, idx, "key", l.key, l.keySize)
+			checkFields(t, mapName, idx, "value", l.value, l.valueSize)
+		}
+	}
+}
+
+// checkFields verifies the fields are named once, within the size and do not overlap
+func checkFields(t *testing.T, mapName string, idx int, part string, fields []field, size int) {
+	t.Helper()
+	names := make(map[string]bool, len(fields))
+	sorted := append([]field{}, fields...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].off < sorted[j].off })
+	end := 0
+	for _, f := range sorted {
+		if names[f.name] {
+			t.Errorf("%s layout %d: %s field %s listed twice", mapName, idx, part, f.name)
+		}
+		names[f.name] = true
+		if f.size <= 0 || f.off < 0 || f.off+f.size > size {
+			t.Errorf("%s layout %d: %s field %s at %d+%d out of %d bytes", mapName, idx, part, f.name, f.off, f.size, size)
+		}
+		if f.off < end {
+			t.Errorf("%s layout %d: %s field %s at %d overlaps the previous field", mapName, idx, part, f.name, f.off)
+		}
+		end = f.off + f.size
+	}
+}
+
+func TestFindLayout(t *testing.T) {
+	for mapName, mapLayouts := range layouts {
+		for idx := range mapLayouts {
+			want := &mapLayouts[idx]
+			got, found := findLayout(mapName, want.keySize, want.valueSize)
+			if !found || got != want {
+				t.Errorf("%s layout %d of sizes %d/%d not found", mapName, idx, want.keySize, want.valueSize)
+			}
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		mapName   string
+		keySize   int
+		valueSize int
+	}{
+		{name: "unknown value size", mapName: bpf.FSM_MAP_NAME_NAT, keySize: 27, valueSize: 1},
+		{name: "unknown key size", mapName: bpf.FSM_MAP_NAME_NAT, keySize: 1, valueSize: 6676},
+		{name: "sizes of another version", mapName: bpf.FSM_MAP_NAME_NAT, keySize: 25, valueSize: 6676},
+		{name: "unknown map", mapName: "fsm_xnone", keySize: 27, valueSize: 6676},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if l, found := findLayout(tc.mapName, tc.keySize, tc.valueSize); found {
+				t.Fatalf("findLayout(%s, %d, %d) = %+v, want not found", tc.mapName, tc.keySize, tc.valueSize, l)
+			}
+		})
+	}
+}
+
+func TestPlanCopies(t *testing.T) {
+	testCases := []struct {
+		name    string
+		old     []field
+		new     []field
+		want    []fieldCopy
+		wantErr bool
+	}{
+		{
+			name: "fields matched by name",
+			old:  []field{{"a", 0, 2}, {"b", 2, 4}},
+			new:  []field{{"b", 0, 4}, {"a", 8, 2}},
+			want: []fieldCopy{{src: 2, dst: 0, size: 4}, {src: 0, dst: 8, size: 2}},
+		},
+		{
+			name: "new fields left zero",
+			old:  []field{{"a", 0, 2}},
+			new:  []field{{"a", 0, 2}, {"c", 2, 4}},
+			want: []fieldCopy{{src: 0, dst: 0, size: 2}},
+		},
+		{
+			name: "dropped fields not copied",
+			old:  []field{{"a", 0, 2}, {"d", 2, 4}},
+			new:  []field{{"a", 4, 2}},
+			want: []fieldCopy{{src: 0, dst: 4, size: 2}},
+		},
+		{
+			name:    "field size changed",
+			old:     []field{{"a", 0, 2}},
+			new:     []field{{"a", 0, 4}},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			copies, err := planCopies(tc.old, tc.new)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("planCopies = %v, want error", copies)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planCopies error: %v", err)
+			}
+			if !reflect.DeepEqual(copies, tc.want) {
+				t.Fatalf("planCopies = %v, want %v", copies, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyCopies(t *testing.T) {
+	src := []byte{1, 2, 3, 4, 5, 6}
+	dst := []byte{9, 9, 9, 9, 9, 9, 9, 9}
+	applyCopies([]fieldCopy{{src: 4, dst: 0, size: 2}, {src: 0, dst: 4, size: 3}}, src, dst)
+	if want := []byte{5, 6, 0, 0, 1, 2, 3, 0}; !bytes.Equal(dst, want) {
+		t.Fatalf("applyCopies = %v, want %v", dst, want)
+	}
+}
+
+func TestMigrateNatValue(t *testing.T) {
+	natLayouts := layouts[bpf.FSM_MAP_NAME_NAT]
+	oldLayout, newLayout := &natLayouts[0], &natLayouts[len(natLayouts)-1]
+
+	keyCopies, err := planCopies(oldLayout.key, newLayout.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valCopies, err := planCopies(oldLayout.value, newLayout.value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// baseline nat_key_t and nat_op_t, eps of 40 bytes from offset 8
+	oldKey := make([]byte, oldLayout.keySize)
+	binary.LittleEndian.PutUint32(oldKey[0:], 1)
+	copy(oldKey[4:20], []byte{10, 0, 0, 1})
+	binary.BigEndian.PutUint16(oldKey[20:], 80)
+	oldKey[22] = 6
+	oldKey[24] = 1
+	oldVal := make([]byte, oldLayout.valueSize)
+	binary.LittleEndian.PutUint16(oldVal[4:], 1)
+	binary.LittleEndian.PutUint16(oldVal[6:], 2)
+	for idx, addr := range [][]byte{{10, 0, 1, 1}, {10, 0, 1, 2}} {
+		ep := oldVal[8+idx*40:]
+		copy(ep[0:16], addr)
+		binary.BigEndian.PutUint16(ep[16:], 8080)
+		binary.LittleEndian.PutUint32(ep[24:], 3)
+		ep[39] = 1
+	}
+
+	newKey := make([]byte, newLayout.keySize)
+	newVal := make([]byte, newLayout.valueSize)
+	applyCopies(keyCopies, oldKey, newKey)
+	applyCopies(valCopies, oldVal, newVal)
+
+	if !bytes.Equal(newKey[:25], oldKey) || newKey[25] != 0 || newKey[26] != 0 {
+		t.Fatalf("migrated key = %v, want %v with a zero dport_hi", newKey, oldKey)
+	}
+	if sel, cnt := binary.LittleEndian.Uint16(newVal[4:]), binary.LittleEndian.Uint16(newVal[6:]); sel != 1 || cnt != 2 {
+		t.Fatalf("migrated ep_sel/ep_cnt = %d/%d, want 1/2", sel, cnt)
+	}
+	if mode, maxConns := newVal[8], binary.LittleEndian.Uint32(newVal[16:]); mode != 0 || maxConns != 0 {
+		t.Fatalf("migrated mode/max_conns = %d/%d, want zero", mode, maxConns)
+	}
+	// current eps of 52 bytes from offset 20
+	for idx, addr := range [][]byte{{10, 0, 1, 1}, {10, 0, 1, 2}} {
+		ep := newVal[20+idx*52:]
+		if !bytes.Equal(ep[0:4], addr) || binary.BigEndian.Uint16(ep[16:]) != 8080 ||
+			binary.LittleEndian.Uint32(ep[24:]) != 3 || ep[39] != 1 {
+			t.Fatalf("migrated ep %d = %v", idx, ep[:52])
+		}
+		if binary.LittleEndian.Uint16(ep[40:]) != 0 || binary.LittleEndian.Uint32(ep[44:]) != 0 ||
+			binary.LittleEndian.Uint32(ep[48:]) != 0 {
+			t.Fatalf("migrated ep %d has non zero new fields: %v", idx, ep[40:52])
+		}
+	}
+	if ep := newVal[20+2*52 : 20+3*52]; !bytes.Equal(ep, make([]byte, 52)) {
+		t.Fatalf("unused ep 2 = %v, want zero", ep)
+	}
+}
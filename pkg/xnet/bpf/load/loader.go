@@ -17,9 +17,12 @@ var (
 		`xnet.kern.o`,
 	}
 	bpfProgPath = ``
+	mapEntries  = make(map[string]uint32)
 	log         = logger.New("fsm-xnet-bpf-load")
 )
 
+// init only finds the bpf prog, a missing one fails the load of its spec,
+// so that the layouts stay testable without a built prog.
 func init() {
 	for _, searchPath := range searchPaths {
 		if exists := util.Exists(searchPath); exists {
@@ -27,5 +30,13 @@ func init() {
 			return
 		}
 	}
-	log.Fatal().Msgf("not found bpf prog: %s", bpfProgPath)
+}
+
+// SetMapEntries overrides the compiled-in max_entries of the named map,
+// it must be called before ProgLoad and is ignored when maxEntries is 0.
+// Pinned maps of another size are migrated into resized ones by ProgLoad.
+func SetMapEntries(mapName string, maxEntries uint32) {
+	if maxEntries > 0 {
+		mapEntries[mapName] = maxEntries
+	}
 }
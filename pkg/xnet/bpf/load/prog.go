@@ -1,76 +1,152 @@
 package load
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"path"
+	"sort"
 	"strconv"
-	"time"
+	"strings"
+
+	"github.com/cilium/ebpf"
 
 	"github.com/flomesh-io/xnet/pkg/xnet/bpf/fs"
 	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
 	"github.com/flomesh-io/xnet/pkg/xnet/util"
 )
 
-const (
-	bpftoolCmd = `bpftool`
-)
+// ProgLoad loads and pins the bpf prog, unless it is already pinned.
+// Returns true if the pinned progs and maps were upgraded, the tc filters
+// still reference the old progs and maps until swapped.
+func ProgLoad() (bool, error) {
+	pinningDir := fs.GetPinningDir()
+	if exists := util.Exists(pinningDir); exists {
+		spec, err := loadCollectionSpec()
+		if err != nil {
+			return false, err
+		}
+		stale, err := stalePins(spec)
+		if err != nil {
+			return false, err
+		}
+		if len(stale) == 0 {
+			return false, nil
+		}
+		// the pins left by another prog version or other map sizes are upgraded
+		log.Info().Msgf("bpf prog will be upgraded: %s", strings.Join(stale, ", "))
+		return true, ProgUpgrade()
+	}
 
-var (
-	searchBinPaths = []string{
-		`/usr/local/bin`,
-		`/usr/sbin`,
+	spec, err := loadCollectionSpec()
+	if err != nil {
+		return false, err
 	}
-)
 
-func findBpftoolPath() (bpftoolBin string, err error) {
-	for _, binPath := range searchBinPaths {
-		bpftoolBin = path.Join(binPath, bpftoolCmd)
-		if exists := util.Exists(bpftoolBin); exists {
-			break
-		}
+	coll, err := newCollection(spec, ebpf.CollectionOptions{})
+	if err != nil {
+		return false, err
 	}
+	defer coll.Close()
 
-	if len(bpftoolBin) == 0 {
-		err = fmt.Errorf("fail to find %s", bpftoolCmd)
+	if err = pinCollection(coll); err != nil {
+		ProgUnload()
+		return false, err
 	}
 
-	return
+	if err = maps.InitProgEntries(); err != nil {
+		ProgUnload()
+		return false, fmt.Errorf("fail to init prog entries: %w", err)
+	}
+
+	return false, nil
 }
 
-func ProgLoad() {
-	pinningDir := fs.GetPinningDir()
-	if exists := util.Exists(pinningDir); exists {
-		return
+func loadCollectionSpec() (*ebpf.CollectionSpec, error) {
+	if len(bpfProgPath) == 0 {
+		return nil, fmt.Errorf("not found bpf prog in %s", strings.Join(searchPaths, ","))
+	}
+	spec, err := ebpf.LoadCollectionSpec(bpfProgPath)
+	if err != nil {
+		return nil, fmt.Errorf("fail to load bpf spec %s: %w", bpfProgPath, err)
 	}
 
-	args := []string{
-		`prog`,
-		`loadall`,
-		bpfProgPath,
-		pinningDir,
-		`pinmaps`,
-		pinningDir,
+	for mapName, maxEntries := range mapEntries {
+		mapSpec, exists := spec.Maps[mapName]
+		if !exists {
+			return nil, fmt.Errorf("fail to resize bpf map %s: not found", mapName)
+		}
+		mapSpec.MaxEntries = maxEntries
 	}
 
-	bpftoolBin, err := findBpftoolPath()
+	return spec, nil
+}
+
+// stalePins returns why the pinned maps and progs do not match the spec,
+// maps of another size or layout and missing maps or progs.
+func stalePins(spec *ebpf.CollectionSpec) ([]string, error) {
+	var stale []string
+	for mapName, mapSpec := range spec.Maps {
+		if strings.HasPrefix(mapName, ".") {
+			continue
+		}
+		pinnedFile := fs.GetPinningFile(mapName)
+		if exists := util.Exists(pinnedFile); !exists {
+			stale = append(stale, fmt.Sprintf("map %s not pinned", mapName))
+			continue
+		}
+		pinnedMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("fail to load pinned bpf map %s: %w", mapName, err)
+		}
+		compatErr := mapSpec.Compatible(pinnedMap)
+		pinnedMap.Close()
+		if compatErr != nil {
+			stale = append(stale, fmt.Sprintf("map %s: %s", mapName, compatErr.Error()))
+		}
+	}
+	for progName := range spec.Programs {
+		if exists := util.Exists(fs.GetPinningFile(progName)); !exists {
+			stale = append(stale, fmt.Sprintf("prog %s not pinned", progName))
+		}
+	}
+	sort.Strings(stale)
+	return stale, nil
+}
+
+func newCollection(spec *ebpf.CollectionSpec, opts ebpf.CollectionOptions) (*ebpf.Collection, error) {
+	coll, err := ebpf.NewCollectionWithOptions(spec, opts)
 	if err != nil {
-		log.Fatal().Err(err).Msgf("fail to find %s", bpftoolCmd)
-		return
+		var verifierErr *ebpf.VerifierError
+		if errors.As(err, &verifierErr) {
+			return nil, fmt.Errorf("fail to load bpf prog %s: %+v", bpfProgPath, verifierErr)
+		}
+		return nil, fmt.Errorf("fail to load bpf prog %s: %w", bpfProgPath, err)
 	}
+	return coll, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
-	defer cancel()
-	cmd := exec.CommandContext(ctx, bpftoolBin, args...) // nolint gosec
-	if output, err := cmd.Output(); err != nil {
-		log.Debug().Msg(err.Error())
-	} else if len(output) > 0 {
-		log.Debug().Msg(string(output))
+func pinCollection(coll *ebpf.Collection) error {
+	if err := os.MkdirAll(fs.GetPinningDir(), 0750); err != nil {
+		return err
+	}
+
+	for name, m := range coll.Maps {
+		if strings.HasPrefix(name, ".") {
+			// internal .rodata/.data/.bss maps, bpffs rejects names with dots
+			continue
+		}
+		if err := m.Pin(fs.GetPinningFile(name)); err != nil {
+			return fmt.Errorf("fail to pin bpf map %s: %w", name, err)
+		}
+	}
+
+	for name, p := range coll.Programs {
+		if err := p.Pin(fs.GetPinningFile(name)); err != nil {
+			return fmt.Errorf("fail to pin bpf prog %s: %w", name, err)
+		}
 	}
 
-	maps.InitProgEntries()
+	return nil
 }
 
 func ProgUnload() {
@@ -86,16 +162,19 @@ func InitMeshConfig(ipv4Magic, ipv6Magic string) {
 	if cfgVal, cfgErr := maps.GetXNetCfg(maps.SysMesh); cfgErr != nil {
 		log.Fatal().Msg(cfgErr.Error())
 	} else {
-		if !cfgVal.IPv4().IsSet(maps.CfgFlagOffsetUDPProtoAllowAll) {
-			if !cfgVal.IPv4().IsSet(maps.CfgFlagOffsetUDPProtoDenyAll) &&
-				!cfgVal.IPv4().IsSet(maps.CfgFlagOffsetUDPNatByIpPortOn) &&
-				!cfgVal.IPv4().IsSet(maps.CfgFlagOffsetUDPNatByIpOn) &&
-				!cfgVal.IPv4().IsSet(maps.CfgFlagOffsetUDPNatByPortOn) &&
-				!cfgVal.IPv4().IsSet(maps.CfgFlagOffsetUDPNatAllOff) {
-				cfgVal.IPv4().Set(maps.CfgFlagOffsetUDPProtoAllowAll)
+		// ipv4 and ipv6 share the same defaults, so that dual-stack pods are intercepted on both families
+		for _, flags := range []*maps.FlagT{cfgVal.IPv4(), cfgVal.IPv6()} {
+			if !flags.IsSet(maps.CfgFlagOffsetUDPProtoAllowAll) {
+				if !flags.IsSet(maps.CfgFlagOffsetUDPProtoDenyAll) &&
+					!flags.IsSet(maps.CfgFlagOffsetUDPNatByIpPortOn) &&
+					!flags.IsSet(maps.CfgFlagOffsetUDPNatByIpOn) &&
+					!flags.IsSet(maps.CfgFlagOffsetUDPNatByPortOn) &&
+					!flags.IsSet(maps.CfgFlagOffsetUDPNatAllOff) {
+					flags.Set(maps.CfgFlagOffsetUDPProtoAllowAll)
+				}
 			}
+			flags.Set(maps.CfgFlagOffsetAclCheckOn)
 		}
-		cfgVal.IPv4().Set(maps.CfgFlagOffsetAclCheckOn)
 
 		if len(ipv4Magic) > 0 {
 			if ipv4Flags, err := strconv.ParseUint(ipv4Magic, 16, 64); err == nil {
@@ -0,0 +1,226 @@
+package load
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/fs"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+	"github.com/flomesh-io/xnet/pkg/xnet/util"
+)
+
+const (
+	upgradePinSuffix = `_upgrade`
+)
+
+// ProgUpgrade loads the bpf prog while keeping the state of the pinned maps.
+// Compatible maps are reused as they are, maps whose layout changed are
+// recreated and their entries migrated. The new progs and maps replace the
+// old pins, the tc filters still reference the old progs until swapped.
+func ProgUpgrade() error {
+	pinningDir := fs.GetPinningDir()
+	if exists := util.Exists(pinningDir); !exists {
+		_, err := ProgLoad()
+		return err
+	}
+
+	spec, err := loadCollectionSpec()
+	if err != nil {
+		return err
+	}
+
+	pinnedMaps := make(map[string]*ebpf.Map)
+	defer func() {
+		for _, pinnedMap := range pinnedMaps {
+			pinnedMap.Close()
+		}
+	}()
+
+	replacements := make(map[string]*ebpf.Map)
+	for name, mapSpec := range spec.Maps {
+		if strings.HasPrefix(name, ".") || mapSpec.Type == ebpf.ProgramArray {
+			continue
+		}
+		pinnedFile := fs.GetPinningFile(name)
+		if exists := util.Exists(pinnedFile); !exists {
+			continue
+		}
+		pinnedMap, mapErr := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+		if mapErr != nil {
+			return fmt.Errorf("fail to load pinned bpf map %s: %w", name, mapErr)
+		}
+		pinnedMaps[name] = pinnedMap
+		if compatErr := mapSpec.Compatible(pinnedMap); compatErr == nil {
+			replacements[name] = pinnedMap
+		} else {
+			log.Info().Msgf("bpf map %s will be migrated: %s", name, compatErr.Error())
+		}
+	}
+
+	coll, err := newCollection(spec, ebpf.CollectionOptions{MapReplacements: replacements})
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+
+	for name, pinnedMap := range pinnedMaps {
+		if _, reused := replacements[name]; reused {
+			continue
+		}
+		// the old pins are kept if any map cannot be migrated
+		if err = migrateMap(name, pinnedMap, coll.Maps[name]); err != nil {
+			return fmt.Errorf("fail to migrate bpf map %s: %w", name, err)
+		}
+	}
+
+	// the new maps and progs are all pinned beside the old pins first,
+	// the old pins are left untouched if any of them fails
+	var staged []string
+	defer func() {
+		for _, name := range staged {
+			_ = os.Remove(fs.GetPinningFile(name + upgradePinSuffix))
+		}
+	}()
+
+	for name, m := range coll.Maps {
+		if _, reused := replacements[name]; reused || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if err = stagePin(name, m.Pin); err != nil {
+			return fmt.Errorf("fail to pin bpf map %s: %w", name, err)
+		}
+		staged = append(staged, name)
+	}
+
+	for name, p := range coll.Programs {
+		if err = stagePin(name, p.Pin); err != nil {
+			return fmt.Errorf("fail to pin bpf prog %s: %w", name, err)
+		}
+		staged = append(staged, name)
+	}
+
+	for _, name := range staged {
+		if err = os.Rename(fs.GetPinningFile(name+upgradePinSuffix), fs.GetPinningFile(name)); err != nil {
+			return fmt.Errorf("fail to replace bpf pin %s: %w", name, err)
+		}
+	}
+
+	if err = maps.InitProgEntries(); err != nil {
+		return fmt.Errorf("fail to init prog entries: %w", err)
+	}
+
+	return nil
+}
+
+// stagePin pins the object beside its old pin, to be renamed over it,
+// so readers of the pinned file never see it missing.
+func stagePin(name string, pin func(string) error) error {
+	upgradeFile := fs.GetPinningFile(name + upgradePinSuffix)
+	if err := os.Remove(upgradeFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return pin(upgradeFile)
+}
+
+// migrateMap copies the entries of the old map into the new one. Maps of the same
+// key and value sizes are copied as they are, the others are converted field by
+// field between the layouts of their prog versions.
+func migrateMap(name string, oldMap, newMap *ebpf.Map) error {
+	switch oldMap.Type() {
+	case ebpf.RingBuf:
+		return nil
+	case ebpf.PerCPUArray, ebpf.PerCPUHash, ebpf.LRUCPUHash:
+		if scratchMaps[name] {
+			log.Info().Msgf("bpf map %s is scratch, not migrated", name)
+			return nil
+		}
+	case ebpf.Hash, ebpf.LRUHash, ebpf.Array, ebpf.LPMTrie:
+	default:
+		return fmt.Errorf("type %s is not migratable", oldMap.Type())
+	}
+	if oldMap.Type() != newMap.Type() {
+		return fmt.Errorf("type changed from %s to %s", oldMap.Type(), newMap.Type())
+	}
+
+	keyCopies, valCopies, err := planMigration(name, oldMap, newMap)
+	if err != nil {
+		return err
+	}
+
+	perCPU := oldMap.Type() == ebpf.PerCPUArray || oldMap.Type() == ebpf.PerCPUHash || oldMap.Type() == ebpf.LRUCPUHash
+	cpus := 1
+	if perCPU {
+		if cpus, err = ebpf.PossibleCPU(); err != nil {
+			return err
+		}
+	}
+
+	oldKey := make([]byte, oldMap.KeySize())
+	newKey := make([]byte, newMap.KeySize())
+	oldVals := make([][]byte, cpus)
+	newVals := make([][]byte, cpus)
+	for cpu := range newVals {
+		newVals[cpu] = make([]byte, newMap.ValueSize())
+	}
+	migrated := 0
+	it := oldMap.Iterate()
+	for {
+		var next bool
+		if perCPU {
+			next = it.Next(&oldKey, oldVals)
+		} else {
+			next = it.Next(&oldKey, &oldVals[0])
+		}
+		if !next {
+			break
+		}
+		applyCopies(keyCopies, oldKey, newKey)
+		for cpu := range newVals {
+			applyCopies(valCopies, oldVals[cpu], newVals[cpu])
+		}
+		if perCPU {
+			err = newMap.Update(newKey, newVals, ebpf.UpdateAny)
+		} else {
+			err = newMap.Update(newKey, newVals[0], ebpf.UpdateAny)
+		}
+		if err != nil {
+			return fmt.Errorf("migrated %d entries: %w", migrated, err)
+		}
+		migrated++
+	}
+	if err = it.Err(); err != nil {
+		return fmt.Errorf("migrated %d entries: %w", migrated, err)
+	}
+	log.Info().Msgf("bpf map %s migrated %d entries", name, migrated)
+	return nil
+}
+
+// planMigration returns how the keys and values of the old map are copied into the new one.
+func planMigration(name string, oldMap, newMap *ebpf.Map) ([]fieldCopy, []fieldCopy, error) {
+	oldKeySize, oldValSize := int(oldMap.KeySize()), int(oldMap.ValueSize())
+	newKeySize, newValSize := int(newMap.KeySize()), int(newMap.ValueSize())
+	if oldKeySize == newKeySize && oldValSize == newValSize {
+		return []fieldCopy{{size: newKeySize}}, []fieldCopy{{size: newValSize}}, nil
+	}
+	oldLayout, found := findLayout(name, oldKeySize, oldValSize)
+	if !found {
+		return nil, nil, fmt.Errorf("unknown layout of key size %d and value size %d", oldKeySize, oldValSize)
+	}
+	newLayout, found := findLayout(name, newKeySize, newValSize)
+	if !found {
+		return nil, nil, fmt.Errorf("unknown layout of key size %d and value size %d", newKeySize, newValSize)
+	}
+	keyCopies, err := planCopies(oldLayout.key, newLayout.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	valCopies, err := planCopies(oldLayout.value, newLayout.value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keyCopies, valCopies, nil
+}
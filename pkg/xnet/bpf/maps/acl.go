@@ -74,6 +74,31 @@ func ShowAclEntries() {
 		}
 		fmt.Printf(`{"key":%s,"value":%s}`, aclKey.String(), aclVal.String())
 	}
+	if cidrItems, err := GetAclCIDREntries(); err == nil {
+		for cidrKey, cidrVal := range cidrItems {
+			if first {
+				first = false
+			} else {
+				fmt.Println(`,`)
+			}
+			fmt.Printf(`{"key":%s,"value":%s}`, cidrKey.String(), cidrVal.String())
+		}
+	} else {
+		log.Error().Err(err).Msg("failed to list cidr acls")
+	}
+	if rangeItems, err := GetAclRangeEntries(); err == nil {
+		for rangeKey, rangeVal := range rangeItems {
+			if first {
+				first = false
+			} else {
+				fmt.Println(`,`)
+			}
+			aclVal := AclVal(rangeVal.Op)
+			fmt.Printf(`{"key":%s,"value":%s}`, rangeKey.rangeString(&rangeVal), aclVal.String())
+		}
+	} else {
+		log.Error().Err(err).Msg("failed to list port range acls")
+	}
 	fmt.Println()
 	fmt.Println(`]`)
 }
@@ -0,0 +1,96 @@
+package maps
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/fs"
+	"github.com/flomesh-io/xnet/pkg/xnet/util"
+)
+
+// aclCIDRFixedPrefixLen is the bits of sys, proto, v6 and port, which are matched before the addr prefix
+const aclCIDRFixedPrefixLen = uint32(64)
+
+// SetCIDR sets the addr prefix of the acl key
+func (t *AclCIDRKey) SetCIDR(cidr *net.IPNet) error {
+	ones, bits := cidr.Mask.Size()
+	if bits == 0 {
+		return fmt.Errorf("invalid cidr mask: %s", cidr)
+	}
+	var err error
+	if t.Addr[0], t.Addr[1], t.Addr[2], t.Addr[3], t.V6, err = util.IPToInt(cidr.IP.Mask(cidr.Mask)); err != nil {
+		return err
+	}
+	if (t.V6 == 1) != (bits == 8*net.IPv6len) {
+		return fmt.Errorf("invalid cidr: %s", cidr)
+	}
+	t.Prefixlen = aclCIDRFixedPrefixLen + uint32(ones)
+	return nil
+}
+
+// CIDR returns the addr prefix of the acl key
+func (t *AclCIDRKey) CIDR() *net.IPNet {
+	ones := int(t.Prefixlen - aclCIDRFixedPrefixLen)
+	if t.V6 == 1 {
+		return &net.IPNet{IP: util.Int4ToIPv6(t.Addr), Mask: net.CIDRMask(ones, 8*net.IPv6len)}
+	}
+	return &net.IPNet{IP: util.IntToIPv4(t.Addr[0]), Mask: net.CIDRMask(ones, 8*net.IPv4len)}
+}
+
+func AddAclCIDREntry(sysId SysID, aclKey *AclCIDRKey, aclVal *AclVal) error {
+	aclKey.Sys = uint32(sysId)
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_ACL_CIDR)
+	if aclMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{}); err == nil {
+		defer aclMap.Close()
+		return aclMap.Update(aclKey, aclVal, ebpf.UpdateAny)
+	} else {
+		return err
+	}
+}
+
+func DelAclCIDREntry(sysId SysID, aclKey *AclCIDRKey) error {
+	aclKey.Sys = uint32(sysId)
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_ACL_CIDR)
+	if aclMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{}); err == nil {
+		defer aclMap.Close()
+		err = aclMap.Delete(aclKey)
+		if errors.Is(err, unix.ENOENT) {
+			return nil
+		}
+		return err
+	} else {
+		return err
+	}
+}
+
+func GetAclCIDREntries() (map[AclCIDRKey]AclVal, error) {
+	items := make(map[AclCIDRKey]AclVal)
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_ACL_CIDR)
+	aclMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		// pinned by a prog without cidr support
+		if errors.Is(err, os.ErrNotExist) {
+			return items, nil
+		}
+		return nil, err
+	}
+	defer aclMap.Close()
+	aclKey := new(AclCIDRKey)
+	aclVal := new(AclVal)
+	it := aclMap.Iterate()
+	for it.Next(aclKey, aclVal) {
+		items[*aclKey] = *aclVal
+	}
+	return items, it.Err()
+}
+
+func (t *AclCIDRKey) String() string {
+	return fmt.Sprintf(`{"sys": "%s","cidr": "%s","port": %d,"proto": "%s"}`,
+		_sys_(t.Sys), t.CIDR(), _port_(t.Port), _proto_(t.Proto))
+}
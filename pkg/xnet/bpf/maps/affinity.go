@@ -0,0 +1,74 @@
+package maps
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/fs"
+)
+
+// NatAffinityDefaultTimeout is the client-ip affinity timeout in seconds, same as kubernetes' default
+const NatAffinityDefaultTimeout = uint32(10800)
+
+func (t NatAffinity) String() string {
+	if t < NatAffinityMax {
+		return natAffinityNames[t]
+	}
+	return ""
+}
+
+// ParseNatAffinity parses a session affinity name
+func ParseNatAffinity(name string) (NatAffinity, error) {
+	for affinity, affinityName := range natAffinityNames {
+		if affinityName == name {
+			return NatAffinity(affinity), nil
+		}
+	}
+	return NatAffinityMax, fmt.Errorf("invalid nat affinity: %s", name)
+}
+
+// SetAffinity sets the session affinity of the nat entry, the timeout is in seconds.
+func (t *NatVal) SetAffinity(affinity NatAffinity, timeout uint32) {
+	t.Affinity = uint8(affinity)
+	if affinity == NatAffinityNone {
+		t.AffinityTimeout = 0
+		return
+	}
+	if timeout == 0 {
+		timeout = NatAffinityDefaultTimeout
+	}
+	t.AffinityTimeout = timeout
+}
+
+// purgeAffinityEntries deletes the affinities of clients to the nat entry.
+func purgeAffinityEntries(natKey *NatKey) error {
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_AFFINITY)
+	affinityMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		// pinned by a prog without affinity support
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer affinityMap.Close()
+
+	var keys []NatAffinityKey
+	affinityKey := new(NatAffinityKey)
+	affinityVal := new(NatAffinityVal)
+	it := affinityMap.Iterate()
+	for it.Next(affinityKey, affinityVal) {
+		if NatKey(affinityKey.Nat) == *natKey {
+			keys = append(keys, *affinityKey)
+		}
+	}
+	if err = it.Err(); err != nil {
+		return err
+	}
+	_, err = deleteKeys(affinityMap, keys)
+	return err
+}
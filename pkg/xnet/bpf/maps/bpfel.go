@@ -1,5 +1,14 @@
 package maps
 
+type FsmAclCidrKeyT struct {
+	Prefixlen uint32
+	Sys       uint32
+	Proto     uint8
+	V6        uint8
+	Port      uint16
+	Addr      [4]uint32
+}
+
 type FsmAclKeyT struct {
 	Sys   uint32
 	Addr  [4]uint32
@@ -13,6 +22,20 @@ type FsmAclOpT struct {
 	Id   uint16
 }
 
+type FsmAclRangeKeyT struct {
+	Prefixlen uint32
+	Sys       uint32
+	Addr      [4]uint32
+	Proto     uint8
+	Port      uint16
+}
+
+type FsmAclRangeOpT struct {
+	Op     FsmAclOpT
+	PortLo uint16
+	PortHi uint16
+}
+
 type FsmCfgT struct {
 	Ipv4 FlagT
 	Ipv6 FlagT
@@ -25,12 +48,14 @@ type FsmFlowTOpT struct {
 	Nfs     [2]uint8
 	Atime   uint64
 	Xnat    struct {
-		Xmac  [6]uint8
-		Rmac  [6]uint8
-		Xaddr [4]uint32
-		Raddr [4]uint32
-		Xport uint16
-		Rport uint16
+		Xmac   [6]uint8
+		Rmac   [6]uint8
+		Xaddr  [4]uint32
+		Raddr  [4]uint32
+		Xport  uint16
+		Rport  uint16
+		Ofi    uint32
+		Oflags uint32
 	}
 	Trans struct {
 		Tcp struct {
@@ -46,7 +71,9 @@ type FsmFlowTOpT struct {
 		}
 	}
 	DoTrans uint8
-	_       [3]byte
+	ConnOn  uint8
+	Conn    FsmNatConnKeyT
+	_       [4]byte
 }
 
 type FsmFlowUOpT struct {
@@ -56,19 +83,23 @@ type FsmFlowUOpT struct {
 	Nfs     [2]uint8
 	Atime   uint64
 	Xnat    struct {
-		Xmac  [6]uint8
-		Rmac  [6]uint8
-		Xaddr [4]uint32
-		Raddr [4]uint32
-		Xport uint16
-		Rport uint16
+		Xmac   [6]uint8
+		Rmac   [6]uint8
+		Xaddr  [4]uint32
+		Raddr  [4]uint32
+		Xport  uint16
+		Rport  uint16
+		Ofi    uint32
+		Oflags uint32
 	}
 	Trans struct {
 		Udp struct{ Conns struct{ Pkts uint32 } }
 		_   [32]byte
 	}
 	DoTrans uint8
-	_       [3]byte
+	ConnOn  uint8
+	Conn    FsmNatConnKeyT
+	_       [4]byte
 }
 
 type FsmFlowT struct {
@@ -93,28 +124,82 @@ type FsmIfNameT struct {
 	Name [16]uint8
 }
 
+type FsmNatConnKeyT struct {
+	Nat      FsmNatKeyT
+	Raddr    [4]uint32
+	Rport    uint16
+	Released uint8
+}
+
+type FsmNatConnT struct{ Conns int64 }
+
 type FsmNatKeyT struct {
-	Sys   uint32
-	Daddr [4]uint32
-	Dport uint16
-	Proto uint8
-	V6    uint8
-	TcDir uint8
+	Sys     uint32
+	Daddr   [4]uint32
+	Dport   uint16
+	Proto   uint8
+	V6      uint8
+	TcDir   uint8
+	DportHi uint16
 }
 
-type FsmNatOpT struct {
-	Lock  struct{ Val uint32 }
+type FsmNatAffinityKeyT struct {
+	Nat   FsmNatKeyT
+	Caddr [4]uint32
+}
+
+type FsmNatAffinityT struct {
+	Atime uint64
+	Raddr [4]uint32
+	Rport uint16
 	EpSel uint16
-	EpCnt uint16
-	Eps   [128]struct {
-		Raddr   [4]uint32
-		Rport   uint16
-		Rmac    [6]uint8
-		Ofi     uint32
-		Oflags  uint32
-		Omac    [6]uint8
-		OmacSet uint8
-		Active  uint8
+	_     [4]byte
+}
+
+type FsmNatMaglevT struct{ Eps [16381]uint8 }
+
+type FsmNatWrrT struct {
+	Cnt uint16
+	Eps [4096]uint8
+}
+
+type FsmNatRangeKeyT struct {
+	Prefixlen uint32
+	Sys       uint32
+	Daddr     [4]uint32
+	Proto     uint8
+	V6        uint8
+	TcDir     uint8
+	Dport     uint16
+}
+
+type FsmNatRangeT struct {
+	DportLo uint16
+	DportHi uint16
+}
+
+type FsmNatOpT struct {
+	Lock            struct{ Val uint32 }
+	EpSel           uint16
+	EpCnt           uint16
+	Mode            uint8
+	Affinity        uint8
+	_               [2]byte
+	AffinityTimeout uint32
+	MaxConns        uint32
+	Eps             [128]struct {
+		Raddr         [4]uint32
+		Rport         uint16
+		Rmac          [6]uint8
+		Ofi           uint32
+		Oflags        uint32
+		Omac          [6]uint8
+		OmacSet       uint8
+		Active        uint8
+		Weight        uint16
+		_             [2]byte
+		DrainDeadline uint32
+		MaxConns      uint32
 	}
 }
 
@@ -128,6 +213,50 @@ type FsmOptKeyT struct {
 	V6    uint8
 }
 
+type FsmRateLimitBucketT struct {
+	Tokens uint64
+	Atime  uint64
+}
+
+type FsmRateLimitKeyT struct {
+	Sys  uint32
+	Addr [4]uint32
+}
+
+type FsmRateLimitT struct {
+	Rate     uint32
+	Burst    uint32
+	Interval uint64
+	Drops    uint64
+}
+
+type FsmStatKeyT struct {
+	Sys    uint32
+	Reason uint32
+}
+
+type FsmStatT struct {
+	Pkts  uint64
+	Bytes uint64
+}
+
+type FsmTrEventT struct {
+	Ts    uint64
+	Ifi   uint32
+	Ofi   uint32
+	Flow  FsmFlowT
+	Event uint8
+	TcDir uint8
+	Acl   uint8
+	Nfs   uint8
+	Trans int8
+	Pad   uint8
+	Xaddr [4]uint32
+	Raddr [4]uint32
+	Xport uint16
+	Rport uint16
+}
+
 type FsmTrIpT struct {
 	Sys  uint32
 	Addr [4]uint32
@@ -0,0 +1,125 @@
+package maps
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/fs"
+)
+
+const occupancyBatchSize = 4096
+
+var MapNames = []string{
+	bpf.FSM_MAP_NAME_PROG,
+	bpf.FSM_MAP_NAME_NAT,
+	bpf.FSM_MAP_NAME_MAGLEV,
+	bpf.FSM_MAP_NAME_WRR,
+	bpf.FSM_MAP_NAME_AFFINITY,
+	bpf.FSM_MAP_NAME_NAT_RANGE,
+	bpf.FSM_MAP_NAME_NAT_CONN,
+	bpf.FSM_MAP_NAME_ACL,
+	bpf.FSM_MAP_NAME_ACL_CIDR,
+	bpf.FSM_MAP_NAME_ACL_RANGE,
+	bpf.FSM_MAP_NAME_RATE_LIMIT,
+	bpf.FSM_MAP_NAME_RATE_BKT,
+	bpf.FSM_MAP_NAME_TCP_FLOW,
+	bpf.FSM_MAP_NAME_UDP_FLOW,
+	bpf.FSM_MAP_NAME_TCP_OPT,
+	bpf.FSM_MAP_NAME_UDP_OPT,
+	bpf.FSM_MAP_NAME_CFG,
+	bpf.FSM_MAP_NAME_IFS,
+	bpf.FSM_MAP_NAME_TRACE_IP,
+	bpf.FSM_MAP_NAME_TRACE_PORT,
+	bpf.FSM_MAP_NAME_STATS,
+	bpf.FSM_MAP_NAME_TRACE_EVT,
+}
+
+func ShowMapCapacities() {
+	first := true
+	fmt.Println(`[`)
+	for _, mapName := range MapNames {
+		pinnedFile := fs.GetPinningFile(mapName)
+		pinnedMap, mapErr := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+		if mapErr != nil {
+			log.Fatal().Err(mapErr).Msgf("failed to load ebpf map: %s", pinnedFile)
+		}
+		info, infoErr := pinnedMap.Info()
+		pinnedMap.Close()
+		if infoErr != nil {
+			log.Fatal().Err(infoErr).Msgf("failed to load ebpf map info: %s", pinnedFile)
+		}
+
+		if first {
+			first = false
+		} else {
+			fmt.Println(`,`)
+		}
+		fmt.Printf(`{"name":"%s","type":"%s","key_size":%d,"value_size":%d,"max_entries":%d}`,
+			mapName, info.Type, info.KeySize, info.ValueSize, info.MaxEntries)
+	}
+	fmt.Println()
+	fmt.Println(`]`)
+}
+
+// GetMapOccupancy returns the number of entries in the pinned map and its capacity.
+func GetMapOccupancy(mapName string) (int, uint32, error) {
+	pinnedFile := fs.GetPinningFile(mapName)
+	pinnedMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer pinnedMap.Close()
+
+	switch pinnedMap.Type() {
+	case ebpf.Array, ebpf.PerCPUArray, ebpf.ProgramArray, ebpf.RingBuf:
+		return int(pinnedMap.MaxEntries()), pinnedMap.MaxEntries(), nil
+	}
+
+	if entries, err := countEntriesBatch(pinnedMap); err == nil {
+		return entries, pinnedMap.MaxEntries(), nil
+	}
+
+	entries := 0
+	var key, nextKey []byte
+	keyPtr := interface{}(nil)
+	for {
+		if err = pinnedMap.NextKey(keyPtr, &nextKey); err != nil {
+			if errors.Is(err, ebpf.ErrKeyNotExist) {
+				break
+			}
+			return entries, pinnedMap.MaxEntries(), err
+		}
+		entries++
+		key = append(key[:0], nextKey...)
+		keyPtr = key
+	}
+	return entries, pinnedMap.MaxEntries(), nil
+}
+
+// countEntriesBatch counts the entries of a hash map a chunk at a time with the batch api,
+// it fails on the kernels and map types without batch lookup, e.g. lpm tries and per-cpu maps.
+func countEntriesBatch(pinnedMap *ebpf.Map) (int, error) {
+	if pinnedMap.Type() != ebpf.Hash && pinnedMap.Type() != ebpf.LRUHash {
+		return 0, ebpf.ErrNotSupported
+	}
+	chunk := int(min(pinnedMap.MaxEntries(), occupancyBatchSize))
+	keys := reflect.MakeSlice(reflect.SliceOf(reflect.ArrayOf(int(pinnedMap.KeySize()), reflect.TypeOf(byte(0)))), chunk, chunk).Interface()
+	vals := reflect.MakeSlice(reflect.SliceOf(reflect.ArrayOf(int(pinnedMap.ValueSize()), reflect.TypeOf(byte(0)))), chunk, chunk).Interface()
+
+	entries := 0
+	cursor := new(ebpf.MapBatchCursor)
+	for {
+		n, err := pinnedMap.BatchLookup(cursor, keys, vals, nil)
+		entries += n
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, err
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package maps
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/fs"
+	"github.com/flomesh-io/xnet/pkg/xnet/util"
+)
+
+// SetMaxConns sets the max concurrent tcp conns of the nat entry, 0 for unlimited.
+func (t *NatVal) SetMaxConns(maxConns uint32) {
+	t.MaxConns = maxConns
+}
+
+// SetEpMaxConns sets the max concurrent tcp conns of the ep, 0 for unlimited.
+// Returns false if the ep is not found.
+func (t *NatVal) SetEpMaxConns(raddr net.IP, rport uint16, maxConns uint32) (bool, error) {
+	ipNb0, ipNb1, ipNb2, ipNb3, _, err := util.IPToInt(raddr)
+	if err != nil {
+		return false, err
+	}
+	portBe := util.HostToNetShort(rport)
+	for idx := 0; idx < int(t.EpCnt) && idx < len(t.Eps); idx++ {
+		if t.Eps[idx].Raddr == [4]uint32{ipNb0, ipNb1, ipNb2, ipNb3} && t.Eps[idx].Rport == portBe {
+			t.Eps[idx].MaxConns = maxConns
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetNatConns returns the current tcp conns of the nat entry, and of its eps in the order of eps.
+func GetNatConns(natKey *NatKey, natVal *NatVal) (int64, []int64, error) {
+	epConns := make([]int64, min(int(natVal.EpCnt), len(natVal.Eps)))
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_NAT_CONN)
+	connMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		// pinned by a prog without conn limit support
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, epConns, nil
+		}
+		return 0, nil, err
+	}
+	defer connMap.Close()
+
+	connKey := NatConnKey{Nat: FsmNatKeyT(*natKey)}
+	conns, err := getNatConns(connMap, connKey)
+	if err != nil {
+		return 0, nil, err
+	}
+	for idx := range epConns {
+		connKey.Raddr = natVal.Eps[idx].Raddr
+		connKey.Rport = natVal.Eps[idx].Rport
+		if epConns[idx], err = getNatConns(connMap, connKey); err != nil {
+			return 0, nil, err
+		}
+	}
+	return conns, epConns, nil
+}
+
+// getNatConns returns the conns opened by the datapath less the ones released by the control plane.
+func getNatConns(connMap *ebpf.Map, connKey NatConnKey) (int64, error) {
+	opened := new(NatConnVal)
+	connKey.Released = 0
+	if err := connMap.Lookup(&connKey, opened); err != nil {
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	released := new(NatConnVal)
+	connKey.Released = 1
+	if err := connMap.Lookup(&connKey, released); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+		return 0, err
+	}
+	return max(opened.Conns-released.Conns, 0), nil
+}
+
+// releaseMu serializes the read-modify-write of the released counts, written from the
+// idle flow flush, the drain and the pod purge. It is process local, so that the conns
+// are released by xctr only, the cli flushes the tcp flows through xctr.
+var releaseMu sync.Mutex
+
+// releaseFlowConns releases the conns counted by the tcp flows deleted by the control plane,
+// which are counted apart from the conns opened by the datapath.
+func releaseFlowConns(conns []NatConnKey) error {
+	if len(conns) == 0 {
+		return nil
+	}
+	releaseMu.Lock()
+	defer releaseMu.Unlock()
+
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_NAT_CONN)
+	connMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		// pinned by a prog without conn limit support
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer connMap.Close()
+
+	releases := make(map[NatConnKey]int64)
+	for _, connKey := range conns {
+		connKey.Released = 1
+		releases[connKey]++
+		connKey.Raddr = [4]uint32{}
+		connKey.Rport = 0
+		releases[connKey]++
+	}
+	for connKey, n := range releases {
+		// conns of purged nat entries are not counted any more
+		openedKey := connKey
+		openedKey.Released = 0
+		if err = connMap.Lookup(&openedKey, new(NatConnVal)); err != nil {
+			if errors.Is(err, ebpf.ErrKeyNotExist) {
+				continue
+			}
+			return err
+		}
+		released := new(NatConnVal)
+		if err = connMap.Lookup(&connKey, released); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return err
+		}
+		released.Conns += n
+		if err = connMap.Update(&connKey, released, ebpf.UpdateAny); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeNatConnEntries deletes the conn counts of the nat entry.
+func purgeNatConnEntries(natKey *NatKey) error {
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_NAT_CONN)
+	connMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		// pinned by a prog without conn limit support
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer connMap.Close()
+
+	var keys []NatConnKey
+	connKey := new(NatConnKey)
+	connVal := new(NatConnVal)
+	it := connMap.Iterate()
+	for it.Next(connKey, connVal) {
+		if NatKey(connKey.Nat) == *natKey {
+			keys = append(keys, *connKey)
+		}
+	}
+	if err = it.Err(); err != nil {
+		return err
+	}
+	_, err = deleteKeys(connMap, keys)
+	return err
+}
+
+// connOf returns where the conn of the tcp flow is counted, or nil if not counted,
+// only the c2s flow of a conn is counted.
+func (t *FlowTCPVal) connOf() *NatConnKey {
+	if t.ConnOn == 0 {
+		return nil
+	}
+	conn := NatConnKey(t.Conn)
+	return &conn
+}
+
+// ConnsString returns the current vs max conns of the nat entry and its eps.
+func (t *NatVal) ConnsString(conns int64, epConns []int64) string {
+	var sb strings.Builder
+	_write_(&sb, fmt.Sprintf(`{"conns": %d,"max_conns": %d,"eps": [`, conns, t.MaxConns))
+	for idx, epConn := range epConns {
+		if idx > 0 {
+			_write_(&sb, `,`)
+		}
+		ep := &t.Eps[idx]
+		_write_(&sb, fmt.Sprintf(`{"raddr": "%s","rport": %d,"conns": %d,"max_conns": %d}`,
+			_ip_(ep.Raddr), _port_(ep.Rport), epConn, ep.MaxConns))
+	}
+	_write_(&sb, `]}`)
+	return sb.String()
+}
+
+// deleteFlowKeys deletes the flows, and releases the conns counted by the deleted ones,
+// conns are nil for the flows not counted.
+func deleteFlowKeys(flowMap *ebpf.Map, keys []FlowKey, conns []*NatConnKey) (int, error) {
+	var released []NatConnKey
+	deleted := 0
+	for idx := range keys {
+		if err := flowMap.Delete(&keys[idx]); err != nil {
+			if errors.Is(err, ebpf.ErrKeyNotExist) {
+				// deleted by the datapath, which releases its conn
+				continue
+			}
+			_ = releaseFlowConns(released)
+			return deleted, err
+		}
+		deleted++
+		if conns[idx] != nil {
+			released = append(released, *conns[idx])
+		}
+	}
+	return deleted, releaseFlowConns(released)
+}
@@ -0,0 +1,239 @@
+package maps
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/fs"
+	"github.com/flomesh-io/xnet/pkg/xnet/util"
+)
+
+// drainBatchSize is the number of flows read at a time while walking the flow maps
+const drainBatchSize = 4096
+
+// tcpStateDoneMask matches the tcp states of flows closing or reset,
+// TCP_STATE_FIN_MASK and TCP_STATE_ERR of the datapath
+const tcpStateDoneMask = uint8(0x08 | 0x10 | 0x20 | 0x40 | 0x80)
+
+type natEpAddr struct {
+	raddr [4]uint32
+	rport uint16
+}
+
+// DrainEp stops new flows to the ep, existing flows are kept until the deadline,
+// the ep is removed by ReapDrainedEps once its flows are idle or the deadline is passed.
+// Returns false if the ep is not found.
+func (t *NatVal) DrainEp(raddr net.IP, rport uint16, deadline time.Time) (bool, error) {
+	ipNb0, ipNb1, ipNb2, ipNb3, _, err := util.IPToInt(raddr)
+	if err != nil {
+		return false, err
+	}
+	portBe := util.HostToNetShort(rport)
+	for idx := 0; idx < int(t.EpCnt) && idx < len(t.Eps); idx++ {
+		ep := &t.Eps[idx]
+		if ep.Raddr == [4]uint32{ipNb0, ipNb1, ipNb2, ipNb3} && ep.Rport == portBe {
+			ep.Active = 0
+			if ep.DrainDeadline == 0 {
+				ep.DrainDeadline = uint32(max(deadline.Unix(), 1))
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ReapDrainedEps removes the draining eps of nat entries, whose flows are idle for idleSeconds
+// or whose deadline is passed, and purges their flows, returns the number of removed eps.
+// Finished tcp flows are idle whatever their access time.
+func ReapDrainedEps(sysId SysID, idleSeconds int) (int, error) {
+	natEntries, err := GetNatEntries()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().Unix()
+	drainingEps := make(map[natEpAddr]bool)
+	for natKey, natVal := range natEntries {
+		if natKey.Sys != uint32(sysId) {
+			continue
+		}
+		for idx := 0; idx < int(natVal.EpCnt) && idx < len(natVal.Eps); idx++ {
+			if ep := &natVal.Eps[idx]; ep.DrainDeadline > 0 && int64(ep.DrainDeadline) > now {
+				drainingEps[natEpAddr{raddr: ep.Raddr, rport: ep.Rport}] = true
+			}
+		}
+	}
+
+	// the flow maps are walked only if some eps may still be busy
+	busyEps := make(map[natEpAddr]bool)
+	if len(drainingEps) > 0 {
+		if busyEps, err = getBusyEps(sysId, drainingEps, idleSeconds); err != nil {
+			return 0, err
+		}
+	}
+
+	drainedEps := make(map[natEpAddr]bool)
+	reaped := 0
+	for natKey, natVal := range natEntries {
+		if natKey.Sys != uint32(sysId) {
+			continue
+		}
+		drained := false
+		// backwards, as the last ep is moved to the removed slot
+		for idx := min(int(natVal.EpCnt), len(natVal.Eps)) - 1; idx >= 0; idx-- {
+			ep := &natVal.Eps[idx]
+			if ep.DrainDeadline == 0 {
+				continue
+			}
+			epAddr := natEpAddr{raddr: ep.Raddr, rport: ep.Rport}
+			if int64(ep.DrainDeadline) > now && busyEps[epAddr] {
+				continue
+			}
+			natVal.delEpAt(idx)
+			drainedEps[epAddr] = true
+			drained = true
+			reaped++
+		}
+		if !drained {
+			continue
+		}
+		if err = AddNatEntry(sysId, &natKey, &natVal); err != nil {
+			return reaped, err
+		}
+	}
+
+	if len(drainedEps) > 0 {
+		if err = purgeEpFlowEntries(bpf.FSM_MAP_NAME_TCP_FLOW, sysId, drainedEps); err != nil {
+			return reaped, err
+		}
+		if err = purgeEpFlowEntries(bpf.FSM_MAP_NAME_UDP_FLOW, sysId, drainedEps); err != nil {
+			return reaped, err
+		}
+	}
+	return reaped, nil
+}
+
+// getBusyEps returns the draining eps nat-ed to by flows neither idle nor finished yet
+func getBusyEps(sysId SysID, drainingEps map[natEpAddr]bool, idleSeconds int) (map[natEpAddr]bool, error) {
+	busyEps := make(map[natEpAddr]bool)
+	uptimeDuration := time.Duration(util.Uptime()) * time.Second
+	idleDuration := time.Duration(idleSeconds) * time.Second
+	for _, emap := range []string{bpf.FSM_MAP_NAME_TCP_FLOW, bpf.FSM_MAP_NAME_UDP_FLOW} {
+		err := forEachEpFlow(emap, sysId, func(flow *epFlow) {
+			if !drainingEps[flow.epAddr] || busyEps[flow.epAddr] || flow.done {
+				return
+			}
+			if uptimeDuration-time.Duration(flow.atime)*time.Nanosecond <= idleDuration {
+				busyEps[flow.epAddr] = true
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return busyEps, nil
+}
+
+// purgeEpFlowEntries deletes the flows nat-ed to the eps, and the flows from the eps
+func purgeEpFlowEntries(emap string, sysId SysID, eps map[natEpAddr]bool) error {
+	var purgeKeys []FlowKey
+	var purgeConns []*NatConnKey
+	err := forEachEpFlow(emap, sysId, func(flow *epFlow) {
+		if eps[flow.epAddr] || eps[natEpAddr{raddr: flow.key.Saddr, rport: flow.key.Sport}] {
+			purgeKeys = append(purgeKeys, *flow.key)
+			purgeConns = append(purgeConns, flow.conn)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	pinnedFile := fs.GetPinningFile(emap)
+	flowMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return err
+	}
+	defer flowMap.Close()
+	_, err = deleteFlowKeys(flowMap, purgeKeys, purgeConns)
+	return err
+}
+
+// epFlow is a flow of the sys with its nat-ed ep
+type epFlow struct {
+	key    *FlowKey
+	atime  uint64
+	epAddr natEpAddr
+	// done is set on tcp flows closing or reset
+	done bool
+	// conn is the counted conn of tcp flows
+	conn *NatConnKey
+}
+
+// forEachEpFlow calls fn with every flow of the sys
+func forEachEpFlow(emap string, sysId SysID, fn func(flow *epFlow)) error {
+	pinnedFile := fs.GetPinningFile(emap)
+	flowMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return err
+	}
+	defer flowMap.Close()
+
+	if emap == bpf.FSM_MAP_NAME_TCP_FLOW {
+		return forEachFlowBatch(flowMap, func(flowKey *FlowKey, flowVal *FlowTCPVal) {
+			if flowKey.Sys == uint32(sysId) {
+				fn(&epFlow{
+					key:    flowKey,
+					atime:  flowVal.Atime,
+					epAddr: natEpAddr{raddr: flowVal.Xnat.Raddr, rport: flowVal.Xnat.Rport},
+					done:   flowVal.Fin == 1 || flowVal.Trans.Tcp.State&tcpStateDoneMask != 0,
+					conn:   flowVal.connOf(),
+				})
+			}
+		})
+	}
+	return forEachFlowBatch(flowMap, func(flowKey *FlowKey, flowVal *FlowUDPVal) {
+		if flowKey.Sys == uint32(sysId) {
+			fn(&epFlow{
+				key:    flowKey,
+				atime:  flowVal.Atime,
+				epAddr: natEpAddr{raddr: flowVal.Xnat.Raddr, rport: flowVal.Xnat.Rport},
+			})
+		}
+	})
+}
+
+// forEachFlowBatch reads the flow map a chunk at a time with the batch api,
+// falling back to the iterator on kernels without batch lookup.
+func forEachFlowBatch[V any](flowMap *ebpf.Map, fn func(*FlowKey, *V)) error {
+	chunk := int(min(flowMap.MaxEntries(), drainBatchSize))
+	flowKeys := make([]FlowKey, chunk)
+	flowVals := make([]V, chunk)
+	cursor := new(ebpf.MapBatchCursor)
+	for first := true; ; first = false {
+		n, err := flowMap.BatchLookup(cursor, flowKeys, flowVals, nil)
+		if first && errors.Is(err, ebpf.ErrNotSupported) {
+			break
+		}
+		for idx := 0; idx < n; idx++ {
+			fn(&flowKeys[idx], &flowVals[idx])
+		}
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	flowKey := new(FlowKey)
+	flowVal := new(V)
+	it := flowMap.Iterate()
+	for it.Next(flowKey, flowVal) {
+		fn(flowKey, flowVal)
+	}
+	return it.Err()
+}
@@ -22,17 +22,30 @@ func AddTCPFlowEntry(sysId SysID, flowKey *FlowKey, flowVal *FlowTCPVal) error {
 	}
 }
 
+// DelTCPFlowEntry deletes the flow and releases its conn, it is called by xctr only.
 func DelTCPFlowEntry(sysId SysID, flowKey *FlowKey) error {
 	flowKey.Sys = uint32(sysId)
 	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_TCP_FLOW)
 	if flowMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{}); err == nil {
 		defer flowMap.Close()
-		return flowMap.Delete(flowKey)
+		flowVal := new(FlowTCPVal)
+		if err = flowMap.Lookup(flowKey, flowVal); err != nil {
+			return err
+		}
+		if err = flowMap.Delete(flowKey); err != nil {
+			return err
+		}
+		if conn := flowVal.connOf(); conn != nil {
+			return releaseFlowConns([]NatConnKey{*conn})
+		}
+		return nil
 	} else {
 		return err
 	}
 }
 
+// FlushIdleTCPFlowEntries deletes up to batchSize idle flows and releases their conns,
+// it is called by xctr only, the cli flushes through it.
 func FlushIdleTCPFlowEntries(sysId SysID, idleSeconds, batchSize int) (int, error) {
 	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_TCP_FLOW)
 	flowMap, mapErr := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
@@ -68,15 +81,17 @@ func FlushIdleTCPFlowEntries(sysId SysID, idleSeconds, batchSize int) (int, erro
 	idleDuration := time.Duration(idleSeconds) * time.Second
 
 	idleFlowKeys := make([]FlowKey, batchSize)
+	idleConns := make([]*NatConnKey, batchSize)
 	idleFlowIdx := 0
 
 	flowKey := new(FlowKey)
-	flowVal := new(FlowUDPVal)
+	flowVal := new(FlowTCPVal)
 	it := flowMap.Iterate()
 	for it.Next(flowKey, flowVal) {
 		escapeDuration := uptimeDuration - time.Duration(flowVal.Atime)*time.Nanosecond
 		if escapeDuration > idleDuration {
 			idleFlowKeys[idleFlowIdx] = *flowKey
+			idleConns[idleFlowIdx] = flowVal.connOf()
 			if natOptOn && (flowVal.Nfs[TC_DIR_EGR]&NF_XNAT == NF_XNAT) {
 				optKey := OptKey{}
 				copy(optKey.Raddr[:], flowVal.Xnat.Xaddr[:])
@@ -104,7 +119,8 @@ func FlushIdleTCPFlowEntries(sysId SysID, idleSeconds, batchSize int) (int, erro
 		if natOptOn && len(idleOptKeys) > 0 {
 			_, _ = optMap.BatchDelete(idleOptKeys[0:], &ebpf.BatchOptions{})
 		}
-		return flowMap.BatchDelete(idleFlowKeys[0:idleFlowIdx], &ebpf.BatchOptions{})
+		// flows deleted meanwhile by the datapath are skipped, their conns released by it
+		return deleteFlowKeys(flowMap, idleFlowKeys[0:idleFlowIdx], idleConns[0:idleFlowIdx])
 	}
 
 	return 0, nil
@@ -0,0 +1,142 @@
+package maps
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/fs"
+)
+
+const (
+	natMaglevSize = uint64(len(NatMaglevVal{}.Eps))
+
+	// natMaglevNoEp marks a slot without endpoint, the datapath falls back to round-robin
+	natMaglevNoEp = uint8(0xff)
+)
+
+func (t NatMode) String() string {
+	if t < NatModeMax {
+		return natModeNames[t]
+	}
+	return ""
+}
+
+// ParseNatMode parses an endpoint selection mode name
+func ParseNatMode(name string) (NatMode, error) {
+	for mode, modeName := range natModeNames {
+		if modeName == name {
+			return NatMode(mode), nil
+		}
+	}
+	return NatModeMax, fmt.Errorf("invalid nat mode: %s", name)
+}
+
+// newMaglevTable builds the maglev lookup table of the active endpoints, weighted by their weights.
+// Endpoints are permuted by their addr and port only, and populated in a sorted order,
+// so that every node builds the same table for the same endpoints, whatever their index.
+func newMaglevTable(natVal *NatVal) *NatMaglevVal {
+	table := new(NatMaglevVal)
+	for n := range table.Eps {
+		table.Eps[n] = natMaglevNoEp
+	}
+
+	type permutation struct {
+		idx    uint8
+		id     []byte
+		weight int
+		offset uint64
+		skip   uint64
+		next   uint64
+	}
+	var perms []*permutation
+	for idx := 0; idx < int(natVal.EpCnt) && idx < len(natVal.Eps); idx++ {
+		ep := &natVal.Eps[idx]
+		if ep.Active == 0 {
+			continue
+		}
+		id := make([]byte, 18)
+		for n, addr := range ep.Raddr {
+			binary.BigEndian.PutUint32(id[n*4:], addr)
+		}
+		binary.BigEndian.PutUint16(id[16:], ep.Rport)
+		perms = append(perms, &permutation{
+			idx:    uint8(idx),
+			id:     id,
+			weight: epWeight(ep.Weight),
+			offset: maglevHash(`offset`, id) % natMaglevSize,
+			skip:   maglevHash(`skip`, id)%(natMaglevSize-1) + 1,
+		})
+	}
+	if len(perms) == 0 {
+		return table
+	}
+	sort.Slice(perms, func(i, j int) bool {
+		return bytes.Compare(perms[i].id, perms[j].id) < 0
+	})
+
+	// each endpoint takes as many slots per round as its weight
+	for filled := uint64(0); ; {
+		for _, perm := range perms {
+			for turn := 0; turn < perm.weight; turn++ {
+				slot := (perm.offset + perm.next*perm.skip) % natMaglevSize
+				for table.Eps[slot] != natMaglevNoEp {
+					perm.next++
+					slot = (perm.offset + perm.next*perm.skip) % natMaglevSize
+				}
+				table.Eps[slot] = perm.idx
+				perm.next++
+				if filled++; filled == natMaglevSize {
+					return table
+				}
+			}
+		}
+	}
+}
+
+func maglevHash(salt string, id []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(salt))
+	_, _ = h.Write(id)
+	return h.Sum64()
+}
+
+// updateMaglevEntry writes the maglev table of the nat entry, or deletes it if maglev is not selected.
+func updateMaglevEntry(natKey *NatKey, natVal *NatVal) error {
+	if natVal.EpCnt == 0 || NatMode(natVal.Mode) != NatModeMaglev {
+		return delMaglevEntry(natKey)
+	}
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_MAGLEV)
+	maglevMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return err
+	}
+	defer maglevMap.Close()
+	return maglevMap.Update(natKey, newMaglevTable(natVal), ebpf.UpdateAny)
+}
+
+func delMaglevEntry(natKey *NatKey) error {
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_MAGLEV)
+	maglevMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		// pinned by a prog without maglev support
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer maglevMap.Close()
+	err = maglevMap.Delete(natKey)
+	if errors.Is(err, unix.ENOENT) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,203 @@
+package maps
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+type maglevTestEp struct {
+	addr   string
+	port   uint16
+	weight uint16
+	active bool
+}
+
+func newMaglevTestNatVal(t *testing.T, eps []maglevTestEp) *NatVal {
+	t.Helper()
+	natVal := new(NatVal)
+	natVal.Mode = uint8(NatModeMaglev)
+	for _, ep := range eps {
+		added, err := natVal.AddEp(net.ParseIP(ep.addr), ep.port, make([]uint8, 6), 0, 0, nil, ep.weight, ep.active)
+		if err != nil || !added {
+			t.Fatalf("fail to add ep %s:%d: %v", ep.addr, ep.port, err)
+		}
+	}
+	return natVal
+}
+
+func newMaglevTestEps(count int) []maglevTestEp {
+	eps := make([]maglevTestEp, 0, count)
+	for n := 0; n < count; n++ {
+		eps = append(eps, maglevTestEp{addr: fmt.Sprintf("10.0.%d.%d", n/256, n%256), port: 8080, active: true})
+	}
+	return eps
+}
+
+// maglevTestOwners maps every slot of the table to the addr:port of its ep
+func maglevTestOwners(natVal *NatVal, table *NatMaglevVal) []string {
+	owners := make([]string, len(table.Eps))
+	for slot, idx := range table.Eps {
+		if idx == natMaglevNoEp {
+			continue
+		}
+		ep := &natVal.Eps[idx]
+		owners[slot] = fmt.Sprintf("%v:%d", ep.Raddr, ep.Rport)
+	}
+	return owners
+}
+
+func TestMaglevTableDistribution(t *testing.T) {
+	testCases := []struct {
+		name string
+		eps  []maglevTestEp
+		// want maps ep index to its expected share of slots in weight units
+		want map[int]int
+	}{
+		{
+			name: "single ep",
+			eps:  newMaglevTestEps(1),
+			want: map[int]int{0: 1},
+		},
+		{
+			name: "equal weights",
+			eps:  newMaglevTestEps(3),
+			want: map[int]int{0: 1, 1: 1, 2: 1},
+		},
+		{
+			name: "many eps",
+			eps:  newMaglevTestEps(100),
+			want: func() map[int]int {
+				want := make(map[int]int)
+				for n := 0; n < 100; n++ {
+					want[n] = 1
+				}
+				return want
+			}(),
+		},
+		{
+			name: "weighted eps",
+			eps: []maglevTestEp{
+				{addr: "10.0.0.1", port: 80, weight: 1, active: true},
+				{addr: "10.0.0.2", port: 80, weight: 3, active: true},
+				{addr: "10.0.0.3", port: 80, weight: 0, active: true},
+			},
+			want: map[int]int{0: 1, 1: 3, 2: 1},
+		},
+		{
+			name: "inactive eps take no slot",
+			eps: []maglevTestEp{
+				{addr: "10.0.0.1", port: 80, active: true},
+				{addr: "10.0.0.2", port: 80, active: false},
+				{addr: "fd00::3", port: 80, active: true},
+			},
+			want: map[int]int{0: 1, 2: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			table := newMaglevTable(newMaglevTestNatVal(t, tc.eps))
+
+			counts := make(map[int]int)
+			for slot, idx := range table.Eps {
+				if idx == natMaglevNoEp {
+					t.Fatalf("slot %d left empty", slot)
+				}
+				counts[int(idx)]++
+			}
+
+			units := 0
+			for _, share := range tc.want {
+				units += share
+			}
+			for idx, count := range counts {
+				share, exists := tc.want[idx]
+				if !exists {
+					t.Fatalf("ep %d takes %d slots, want none", idx, count)
+				}
+				// slots are taken in rounds of the eps' weights,
+				// so the shares are exact but for the last round
+				expected := int(natMaglevSize) * share / units
+				if diff := count - expected; diff < -share || diff > share {
+					t.Errorf("ep %d takes %d slots, want %d±%d", idx, count, expected, share)
+				}
+			}
+			if len(counts) != len(tc.want) {
+				t.Errorf("%d eps take slots, want %d", len(counts), len(tc.want))
+			}
+		})
+	}
+}
+
+func TestMaglevTableNoActiveEp(t *testing.T) {
+	testCases := []struct {
+		name string
+		eps  []maglevTestEp
+	}{
+		{name: "no ep"},
+		{name: "inactive eps", eps: []maglevTestEp{{addr: "10.0.0.1", port: 80}, {addr: "10.0.0.2", port: 80}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			table := newMaglevTable(newMaglevTestNatVal(t, tc.eps))
+			for slot, idx := range table.Eps {
+				if idx != natMaglevNoEp {
+					t.Fatalf("slot %d points at ep %d, want none", slot, idx)
+				}
+			}
+		})
+	}
+}
+
+func TestMaglevTablePermutation(t *testing.T) {
+	eps := newMaglevTestEps(10)
+	natVal := newMaglevTestNatVal(t, eps)
+	owners := maglevTestOwners(natVal, newMaglevTable(natVal))
+
+	t.Run("deterministic", func(t *testing.T) {
+		again := maglevTestOwners(natVal, newMaglevTable(natVal))
+		for slot := range owners {
+			if owners[slot] != again[slot] {
+				t.Fatalf("slot %d owned by %s then by %s", slot, owners[slot], again[slot])
+			}
+		}
+	})
+
+	t.Run("independent of ep order", func(t *testing.T) {
+		reversed := make([]maglevTestEp, 0, len(eps))
+		for n := len(eps) - 1; n >= 0; n-- {
+			reversed = append(reversed, eps[n])
+		}
+		reversedVal := newMaglevTestNatVal(t, reversed)
+		reversedOwners := maglevTestOwners(reversedVal, newMaglevTable(reversedVal))
+		for slot := range owners {
+			if owners[slot] != reversedOwners[slot] {
+				t.Fatalf("slot %d owned by %s, by %s once eps reordered", slot, owners[slot], reversedOwners[slot])
+			}
+		}
+	})
+
+	t.Run("minimal disruption", func(t *testing.T) {
+		removed := fmt.Sprintf("%v:%d", natVal.Eps[3].Raddr, natVal.Eps[3].Rport)
+		remainVal := newMaglevTestNatVal(t, append(append([]maglevTestEp{}, eps[:3]...), eps[4:]...))
+		remainOwners := maglevTestOwners(remainVal, newMaglevTable(remainVal))
+
+		kept, moved := 0, 0
+		for slot := range owners {
+			if owners[slot] == removed {
+				continue
+			}
+			if owners[slot] == remainOwners[slot] {
+				kept++
+			} else {
+				moved++
+			}
+		}
+		// the slots of the remaining eps mostly stay where they were
+		if total := kept + moved; moved*10 > total {
+			t.Errorf("%d of %d slots of the remaining eps moved", moved, total)
+		}
+	})
+}
@@ -16,24 +16,54 @@ import (
 
 func AddNatEntry(sysId SysID, natKey *NatKey, natVal *NatVal) error {
 	natKey.Sys = uint32(sysId)
+	// the wrr sequence is written first, the datapath skips eps not yet updated
+	if err := updateWrrEntry(natKey, natVal); err != nil {
+		return err
+	}
 	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_NAT)
-	if natMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{}); err == nil {
-		defer natMap.Close()
-		if natVal.EpCnt > 0 {
-			return natMap.Update(natKey, natVal, ebpf.UpdateAny)
-		}
-		err = natMap.Delete(natKey)
-		if errors.Is(err, unix.ENOENT) {
-			return nil
-		}
+	natMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
 		return err
+	}
+	defer natMap.Close()
+	if natVal.EpCnt > 0 {
+		if err = updateNatRangeEntries(natKey); err != nil {
+			return err
+		}
+		err = natMap.Update(natKey, natVal, ebpf.UpdateAny)
 	} else {
+		if err = delNatRangeEntries(natKey); err != nil {
+			return err
+		}
+		if err = natMap.Delete(natKey); errors.Is(err, unix.ENOENT) {
+			err = nil
+		}
+	}
+	if err != nil {
 		return err
 	}
+	// the maglev table follows the nat value it is built from,
+	// the datapath falls back to round-robin until it is written
+	return updateMaglevEntry(natKey, natVal)
 }
 
 func DelNatEntry(sysId SysID, natKey *NatKey) error {
 	natKey.Sys = uint32(sysId)
+	if err := delMaglevEntry(natKey); err != nil {
+		return err
+	}
+	if err := delWrrEntry(natKey); err != nil {
+		return err
+	}
+	if err := purgeAffinityEntries(natKey); err != nil {
+		return err
+	}
+	if err := delNatRangeEntries(natKey); err != nil {
+		return err
+	}
+	if err := purgeNatConnEntries(natKey); err != nil {
+		return err
+	}
 	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_NAT)
 	if natMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{}); err == nil {
 		defer natMap.Close()
@@ -60,14 +90,33 @@ func GetNatEntry(sysId SysID, natKey *NatKey) (*NatVal, error) {
 	}
 }
 
+func GetNatEntries() (map[NatKey]NatVal, error) {
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_NAT)
+	natMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer natMap.Close()
+
+	items := make(map[NatKey]NatVal)
+	natKey := new(NatKey)
+	natVal := new(NatVal)
+	it := natMap.Iterate()
+	for it.Next(natKey, natVal) {
+		items[*natKey] = *natVal
+	}
+	return items, it.Err()
+}
+
 func (t *NatKey) String() string {
-	return fmt.Sprintf(`{"sys": "%s","daddr": "%s","dport": %d,"proto": "%s","v6": %t,"tc_dir": "%s"}`,
-		_sys_(t.Sys), _ip_(t.Daddr), _port_(t.Dport), _proto_(t.Proto), _bool_(t.V6), _tc_dir_(t.TcDir))
+	return fmt.Sprintf(`{"sys": "%s","daddr": "%s","dport": %d,"dport_hi": %d,"proto": "%s","v6": %t,"tc_dir": "%s"}`,
+		_sys_(t.Sys), _ip_(t.Daddr), _port_(t.Dport), _port_(t.DportHi), _proto_(t.Proto), _bool_(t.V6), _tc_dir_(t.TcDir))
 }
 
 func (t *NatVal) String() string {
 	var sb strings.Builder
-	_write_(&sb, fmt.Sprintf(`{"ep_sel": %d,"ep_cnt": %d,"eps": [`, t.EpSel, t.EpCnt))
+	_write_(&sb, fmt.Sprintf(`{"mode": "%s","affinity": "%s","affinity_timeout": %d,"max_conns": %d,"ep_sel": %d,"ep_cnt": %d,"eps": [`,
+		NatMode(t.Mode).String(), NatAffinity(t.Affinity).String(), t.AffinityTimeout, t.MaxConns, t.EpSel, t.EpCnt))
 	for idx, ep := range t.Eps {
 		if idx >= int(t.EpCnt) {
 			break
@@ -75,14 +124,14 @@ func (t *NatVal) String() string {
 		if idx > 0 {
 			_write_(&sb, `,`)
 		}
-		_write_(&sb, fmt.Sprintf(`{"rmac": "%s","raddr": "%s","rport": %d,"ofi": %d,"oflags": %d,"omac_set": %t,"omac": "%s","active": %t}`,
-			_mac_(ep.Rmac[:]), _ip_(ep.Raddr), _port_(ep.Rport), ep.Ofi, ep.Oflags, _bool_(ep.OmacSet), _mac_(ep.Omac[:]), _bool_(ep.Active)))
+		_write_(&sb, fmt.Sprintf(`{"rmac": "%s","raddr": "%s","rport": %d,"ofi": %d,"oflags": %d,"omac_set": %t,"omac": "%s","weight": %d,"active": %t,"draining": %t,"max_conns": %d}`,
+			_mac_(ep.Rmac[:]), _ip_(ep.Raddr), _port_(ep.Rport), ep.Ofi, ep.Oflags, _bool_(ep.OmacSet), _mac_(ep.Omac[:]), epWeight(ep.Weight), _bool_(ep.Active), ep.DrainDeadline > 0, ep.MaxConns))
 	}
 	_write_(&sb, `]}`)
 	return sb.String()
 }
 
-func (t *NatVal) AddEp(raddr net.IP, rport uint16, rmac []uint8, ofi, oflags uint32, omac []uint8, active bool) (bool, error) {
+func (t *NatVal) AddEp(raddr net.IP, rport uint16, rmac []uint8, ofi, oflags uint32, omac []uint8, weight uint16, active bool) (bool, error) {
 	ipNb0, ipNb1, ipNb2, ipNb3, _, err := util.IPToInt(raddr)
 	if err != nil {
 		return false, err
@@ -111,11 +160,13 @@ func (t *NatVal) AddEp(raddr net.IP, rport uint16, rmac []uint8, ofi, oflags uin
 				} else {
 					t.Eps[idx].OmacSet = 0
 				}
+				t.Eps[idx].Weight = weight
 				if active {
 					t.Eps[idx].Active = 1
 				} else {
 					t.Eps[idx].Active = 0
 				}
+				t.Eps[idx].DrainDeadline = 0
 				return true, nil
 			}
 		}
@@ -146,6 +197,7 @@ func (t *NatVal) AddEp(raddr net.IP, rport uint16, rmac []uint8, ofi, oflags uin
 	} else {
 		t.Eps[t.EpCnt].OmacSet = 0
 	}
+	t.Eps[t.EpCnt].Weight = weight
 	if active {
 		t.Eps[t.EpCnt].Active = 1
 	} else {
@@ -155,6 +207,21 @@ func (t *NatVal) AddEp(raddr net.IP, rport uint16, rmac []uint8, ofi, oflags uin
 	return true, nil
 }
 
+// HasEp returns whether the ep is in the nat entry
+func (t *NatVal) HasEp(raddr net.IP, rport uint16) bool {
+	ipNb0, ipNb1, ipNb2, ipNb3, _, err := util.IPToInt(raddr)
+	if err != nil {
+		return false
+	}
+	portBe := util.HostToNetShort(rport)
+	for idx := 0; idx < int(t.EpCnt) && idx < len(t.Eps); idx++ {
+		if t.Eps[idx].Raddr == [4]uint32{ipNb0, ipNb1, ipNb2, ipNb3} && t.Eps[idx].Rport == portBe {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *NatVal) DelEp(raddr net.IP, rport uint16) error {
 	ipNb0, ipNb1, ipNb2, ipNb3, _, err := util.IPToInt(raddr)
 	if err != nil {
@@ -166,41 +233,33 @@ func (t *NatVal) DelEp(raddr net.IP, rport uint16) error {
 	}
 
 	portBe := util.HostToNetShort(rport)
-	hitIdx := -1
-	lastIdx := int(t.EpCnt - 1)
-
 	for idx := range t.Eps {
 		if t.Eps[idx].Raddr[0] == ipNb0 &&
 			t.Eps[idx].Raddr[1] == ipNb1 &&
 			t.Eps[idx].Raddr[2] == ipNb2 &&
 			t.Eps[idx].Raddr[3] == ipNb3 &&
 			t.Eps[idx].Rport == portBe {
-			hitIdx = idx
+			t.delEpAt(idx)
 			break
 		}
 	}
 
-	if hitIdx == -1 {
-		return nil
-	}
+	return nil
+}
 
-	if hitIdx == lastIdx {
-		t.Eps[hitIdx].Raddr[0] = 0
-		t.Eps[hitIdx].Rport = 0
-		t.Eps[hitIdx].Active = 0
-	} else {
-		t.Eps[hitIdx].Raddr[0] = t.Eps[lastIdx].Raddr[0]
-		t.Eps[hitIdx].Rport = t.Eps[lastIdx].Rport
-		t.Eps[hitIdx].Active = t.Eps[lastIdx].Active
+func (t *NatVal) delEpAt(hitIdx int) {
+	if hitIdx >= int(t.EpCnt) {
+		return
+	}
+	lastIdx := int(t.EpCnt - 1)
 
-		t.Eps[lastIdx].Raddr[0] = 0
-		t.Eps[lastIdx].Rport = 0
-		t.Eps[lastIdx].Active = 0
+	// the last ep is moved to the hit slot with all its fields
+	if hitIdx != lastIdx {
+		t.Eps[hitIdx] = t.Eps[lastIdx]
 	}
+	clear(t.Eps[lastIdx : lastIdx+1])
 
 	t.EpCnt--
-
-	return nil
 }
 
 func ShowNatEntries() {
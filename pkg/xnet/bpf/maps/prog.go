@@ -38,6 +38,10 @@ func InitProgEntries() error {
 			progKey:  ProgKey(bpf.FSM_FLOW_PROG_KEY),
 			progName: bpf.FSM_FLOW_PROG_NAME,
 		},
+		{
+			progKey:  ProgKey(bpf.FSM_REJECT_PROG_KEY),
+			progName: bpf.FSM_REJECT_PROG_NAME,
+		},
 	}
 
 	for _, prog := range progs {
@@ -0,0 +1,155 @@
+package maps
+
+import (
+	"errors"
+	"net"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/fs"
+	"github.com/flomesh-io/xnet/pkg/xnet/util"
+)
+
+// PurgeAddrEntries deletes the tcp/udp flow and opt entries of the systems referencing any of the addrs,
+// walking every map once, returns the number of deleted entries.
+func PurgeAddrEntries(sysIds []SysID, addrs []net.IP) (int, error) {
+	target := addrPurgeTarget{
+		sys:  make(map[uint32]bool, len(sysIds)),
+		addr: make(map[[4]uint32]bool, len(addrs)),
+	}
+	for _, sysId := range sysIds {
+		target.sys[uint32(sysId)] = true
+	}
+	for _, addr := range addrs {
+		ipNb0, ipNb1, ipNb2, ipNb3, _, err := util.IPToInt(addr)
+		if err != nil {
+			return 0, err
+		}
+		target.addr[[4]uint32{ipNb0, ipNb1, ipNb2, ipNb3}] = true
+	}
+
+	purged := 0
+	n, err := purgeTCPFlowEntries(&target)
+	purged += n
+	if err != nil {
+		return purged, err
+	}
+	n, err = purgeUDPFlowEntries(&target)
+	purged += n
+	if err != nil {
+		return purged, err
+	}
+	n, err = purgeOptEntries(bpf.FSM_MAP_NAME_TCP_OPT, &target)
+	purged += n
+	if err != nil {
+		return purged, err
+	}
+	n, err = purgeOptEntries(bpf.FSM_MAP_NAME_UDP_OPT, &target)
+	purged += n
+	return purged, err
+}
+
+// addrPurgeTarget is the set of systems and addrs whose entries are purged.
+type addrPurgeTarget struct {
+	sys  map[uint32]bool
+	addr map[[4]uint32]bool
+}
+
+func (t *addrPurgeTarget) matches(sysId uint32, ipNbs ...[4]uint32) bool {
+	if !t.sys[sysId] {
+		return false
+	}
+	for _, ipNb := range ipNbs {
+		if t.addr[ipNb] {
+			return true
+		}
+	}
+	return false
+}
+
+func purgeTCPFlowEntries(target *addrPurgeTarget) (int, error) {
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_TCP_FLOW)
+	flowMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer flowMap.Close()
+
+	var purgeKeys []FlowKey
+	var purgeConns []*NatConnKey
+	flowKey := new(FlowKey)
+	flowVal := new(FlowTCPVal)
+	it := flowMap.Iterate()
+	for it.Next(flowKey, flowVal) {
+		if target.matches(flowKey.Sys, flowKey.Daddr, flowKey.Saddr, flowVal.Xnat.Xaddr, flowVal.Xnat.Raddr) {
+			purgeKeys = append(purgeKeys, *flowKey)
+			purgeConns = append(purgeConns, flowVal.connOf())
+		}
+	}
+	if err = it.Err(); err != nil {
+		return 0, err
+	}
+	return deleteFlowKeys(flowMap, purgeKeys, purgeConns)
+}
+
+func purgeUDPFlowEntries(target *addrPurgeTarget) (int, error) {
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_UDP_FLOW)
+	flowMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer flowMap.Close()
+
+	var purgeKeys []FlowKey
+	flowKey := new(FlowKey)
+	flowVal := new(FlowUDPVal)
+	it := flowMap.Iterate()
+	for it.Next(flowKey, flowVal) {
+		if target.matches(flowKey.Sys, flowKey.Daddr, flowKey.Saddr, flowVal.Xnat.Xaddr, flowVal.Xnat.Raddr) {
+			purgeKeys = append(purgeKeys, *flowKey)
+		}
+	}
+	if err = it.Err(); err != nil {
+		return 0, err
+	}
+	return deleteKeys(flowMap, purgeKeys)
+}
+
+func purgeOptEntries(emap string, target *addrPurgeTarget) (int, error) {
+	pinnedFile := fs.GetPinningFile(emap)
+	optMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer optMap.Close()
+
+	var purgeKeys []OptKey
+	optKey := new(OptKey)
+	optVal := new(OptVal)
+	it := optMap.Iterate()
+	for it.Next(optKey, optVal) {
+		if target.matches(optKey.Sys, optKey.Laddr, optKey.Raddr, optVal.Daddr, optVal.Saddr) {
+			purgeKeys = append(purgeKeys, *optKey)
+		}
+	}
+	if err = it.Err(); err != nil {
+		return 0, err
+	}
+	return deleteKeys(optMap, purgeKeys)
+}
+
+func deleteKeys[K any](emap *ebpf.Map, keys []K) (int, error) {
+	deleted := 0
+	for idx := range keys {
+		if err := emap.Delete(&keys[idx]); err != nil {
+			if errors.Is(err, unix.ENOENT) {
+				continue
+			}
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,247 @@
+package maps
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/fs"
+	"github.com/flomesh-io/xnet/pkg/xnet/util"
+)
+
+const (
+	// aclRangeFixedPrefixLen is the bits of sys, addr and proto, which are matched before the port prefix
+	aclRangeFixedPrefixLen = uint32(168)
+	// natRangeFixedPrefixLen is the bits of sys, daddr, proto, v6 and tc_dir, which are matched before the dport prefix
+	natRangeFixedPrefixLen = uint32(184)
+)
+
+// portPrefix is the aligned block of ports sharing the leading bits of port
+type portPrefix struct {
+	port uint16
+	bits uint32
+}
+
+// ParsePortRange parses a port range, e.g. 30000-30100
+func ParsePortRange(s string) (uint16, uint16, error) {
+	loStr, hiStr, found := strings.Cut(s, "-")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid port range: %s", s)
+	}
+	lo, loErr := strconv.ParseUint(strings.TrimSpace(loStr), 10, 16)
+	hi, hiErr := strconv.ParseUint(strings.TrimSpace(hiStr), 10, 16)
+	if loErr != nil || hiErr != nil || lo == 0 || lo > hi {
+		return 0, 0, fmt.Errorf("invalid port range: %s", s)
+	}
+	return uint16(lo), uint16(hi), nil
+}
+
+// splitPortRange splits the ports [lo, hi] into the fewest aligned port prefixes
+func splitPortRange(lo, hi uint16) []portPrefix {
+	var prefixes []portPrefix
+	for port := uint32(lo); port <= uint32(hi); {
+		size, bits := uint32(1), uint32(16)
+		for bits > 0 && port&(size<<1-1) == 0 && port+size<<1-1 <= uint32(hi) {
+			size <<= 1
+			bits--
+		}
+		prefixes = append(prefixes, portPrefix{port: uint16(port), bits: bits})
+		port += size
+	}
+	return prefixes
+}
+
+func overlapped(lo1, hi1, lo2, hi2 uint16) bool {
+	return lo1 <= hi2 && lo2 <= hi1
+}
+
+// AddAclRangeEntry adds the acl of the ports [portLo, portHi] of the key's addr and proto,
+// the port of the key is ignored.
+func AddAclRangeEntry(sysId SysID, aclKey *AclKey, portLo, portHi uint16, aclVal *AclVal) error {
+	if portLo == 0 || portLo > portHi {
+		return fmt.Errorf("invalid port range: %d-%d", portLo, portHi)
+	}
+	aclKey.Sys = uint32(sysId)
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_ACL_RANGE)
+	rangeMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return err
+	}
+	defer rangeMap.Close()
+
+	// overlapped ranges would share port prefixes
+	rangeKey := new(AclRangeKey)
+	rangeVal := new(AclRangeVal)
+	it := rangeMap.Iterate()
+	for it.Next(rangeKey, rangeVal) {
+		if rangeKey.Sys != aclKey.Sys || rangeKey.Addr != aclKey.Addr || rangeKey.Proto != aclKey.Proto {
+			continue
+		}
+		lo, hi := util.NetToHostShort(rangeVal.PortLo), util.NetToHostShort(rangeVal.PortHi)
+		if (lo != portLo || hi != portHi) && overlapped(lo, hi, portLo, portHi) {
+			return fmt.Errorf("port range %d-%d overlaps %d-%d", portLo, portHi, lo, hi)
+		}
+	}
+	if err = it.Err(); err != nil {
+		return err
+	}
+
+	newVal := AclRangeVal{
+		Op:     FsmAclOpT(*aclVal),
+		PortLo: util.HostToNetShort(portLo),
+		PortHi: util.HostToNetShort(portHi),
+	}
+	for _, prefix := range splitPortRange(portLo, portHi) {
+		newKey := newAclRangeKey(aclKey, prefix)
+		if err = rangeMap.Update(&newKey, &newVal, ebpf.UpdateAny); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DelAclRangeEntry deletes the acl of the ports [portLo, portHi] of the key's addr and proto
+func DelAclRangeEntry(sysId SysID, aclKey *AclKey, portLo, portHi uint16) error {
+	if portLo == 0 || portLo > portHi {
+		return fmt.Errorf("invalid port range: %d-%d", portLo, portHi)
+	}
+	aclKey.Sys = uint32(sysId)
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_ACL_RANGE)
+	rangeMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return err
+	}
+	defer rangeMap.Close()
+	for _, prefix := range splitPortRange(portLo, portHi) {
+		rangeKey := newAclRangeKey(aclKey, prefix)
+		if err = rangeMap.Delete(&rangeKey); err != nil && !errors.Is(err, unix.ENOENT) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAclRangeEntries returns the acls of port ranges, keyed by their first port prefixes
+func GetAclRangeEntries() (map[AclRangeKey]AclRangeVal, error) {
+	items := make(map[AclRangeKey]AclRangeVal)
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_ACL_RANGE)
+	rangeMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		// pinned by a prog without port range support
+		if errors.Is(err, os.ErrNotExist) {
+			return items, nil
+		}
+		return nil, err
+	}
+	defer rangeMap.Close()
+	rangeKey := new(AclRangeKey)
+	rangeVal := new(AclRangeVal)
+	it := rangeMap.Iterate()
+	for it.Next(rangeKey, rangeVal) {
+		if rangeKey.Port == rangeVal.PortLo {
+			items[*rangeKey] = *rangeVal
+		}
+	}
+	return items, it.Err()
+}
+
+func newAclRangeKey(aclKey *AclKey, prefix portPrefix) AclRangeKey {
+	return AclRangeKey{
+		Prefixlen: aclRangeFixedPrefixLen + prefix.bits,
+		Sys:       aclKey.Sys,
+		Addr:      aclKey.Addr,
+		Proto:     aclKey.Proto,
+		Port:      util.HostToNetShort(prefix.port),
+	}
+}
+
+func (t *AclRangeKey) rangeString(rangeVal *AclRangeVal) string {
+	return fmt.Sprintf(`{"sys": "%s","addr": "%s","port_range": "%d-%d","proto": "%s"}`,
+		_sys_(t.Sys), _ip_(t.Addr), _port_(rangeVal.PortLo), _port_(rangeVal.PortHi), _proto_(t.Proto))
+}
+
+// updateNatRangeEntries writes the dport prefixes of the nat entry of a dport range
+func updateNatRangeEntries(natKey *NatKey) error {
+	if natKey.DportHi == 0 {
+		return nil
+	}
+	dportLo, dportHi := util.NetToHostShort(natKey.Dport), util.NetToHostShort(natKey.DportHi)
+	if dportLo == 0 || dportLo > dportHi {
+		return fmt.Errorf("invalid port range: %d-%d", dportLo, dportHi)
+	}
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_NAT_RANGE)
+	rangeMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return err
+	}
+	defer rangeMap.Close()
+
+	// overlapped ranges would share dport prefixes
+	rangeKey := new(NatRangeKey)
+	rangeVal := new(NatRangeVal)
+	it := rangeMap.Iterate()
+	for it.Next(rangeKey, rangeVal) {
+		if rangeKey.Sys != natKey.Sys || rangeKey.Daddr != natKey.Daddr || rangeKey.Proto != natKey.Proto ||
+			rangeKey.V6 != natKey.V6 || rangeKey.TcDir != natKey.TcDir {
+			continue
+		}
+		lo, hi := util.NetToHostShort(rangeVal.DportLo), util.NetToHostShort(rangeVal.DportHi)
+		if (lo != dportLo || hi != dportHi) && overlapped(lo, hi, dportLo, dportHi) {
+			return fmt.Errorf("port range %d-%d overlaps %d-%d", dportLo, dportHi, lo, hi)
+		}
+	}
+	if err = it.Err(); err != nil {
+		return err
+	}
+
+	newVal := NatRangeVal{DportLo: natKey.Dport, DportHi: natKey.DportHi}
+	for _, prefix := range splitPortRange(dportLo, dportHi) {
+		newKey := newNatRangeKey(natKey, prefix)
+		if err = rangeMap.Update(&newKey, &newVal, ebpf.UpdateAny); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func delNatRangeEntries(natKey *NatKey) error {
+	dportLo, dportHi := util.NetToHostShort(natKey.Dport), util.NetToHostShort(natKey.DportHi)
+	if natKey.DportHi == 0 || dportLo == 0 || dportLo > dportHi {
+		return nil
+	}
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_NAT_RANGE)
+	rangeMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		// pinned by a prog without port range support
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer rangeMap.Close()
+	for _, prefix := range splitPortRange(dportLo, dportHi) {
+		rangeKey := newNatRangeKey(natKey, prefix)
+		if err = rangeMap.Delete(&rangeKey); err != nil && !errors.Is(err, unix.ENOENT) {
+			return err
+		}
+	}
+	return nil
+}
+
+func newNatRangeKey(natKey *NatKey, prefix portPrefix) NatRangeKey {
+	return NatRangeKey{
+		Prefixlen: natRangeFixedPrefixLen + prefix.bits,
+		Sys:       natKey.Sys,
+		Daddr:     natKey.Daddr,
+		Proto:     natKey.Proto,
+		V6:        natKey.V6,
+		TcDir:     natKey.TcDir,
+		Dport:     util.HostToNetShort(prefix.port),
+	}
+}
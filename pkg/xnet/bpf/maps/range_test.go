@@ -0,0 +1,159 @@
+package maps
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortRange(t *testing.T) {
+	testCases := []struct {
+		spec    string
+		wantLo  uint16
+		wantHi  uint16
+		wantErr bool
+	}{
+		{spec: "30000-30100", wantLo: 30000, wantHi: 30100},
+		{spec: " 1000 - 2000 ", wantLo: 1000, wantHi: 2000},
+		{spec: "80-80", wantLo: 80, wantHi: 80},
+		{spec: "1-65535", wantLo: 1, wantHi: 65535},
+		{spec: "65535-65535", wantLo: 65535, wantHi: 65535},
+		{spec: "0-100", wantErr: true},
+		{spec: "0-65535", wantErr: true},
+		{spec: "80", wantErr: true},
+		{spec: "200-100", wantErr: true},
+		{spec: "1-65536", wantErr: true},
+		{spec: "-100", wantErr: true},
+		{spec: "100-", wantErr: true},
+		{spec: "a-b", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			lo, hi, err := ParsePortRange(tc.spec)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ParsePortRange(%q) = %d-%d, want error", tc.spec, lo, hi)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePortRange(%q) error: %v", tc.spec, err)
+			}
+			if lo != tc.wantLo || hi != tc.wantHi {
+				t.Fatalf("ParsePortRange(%q) = %d-%d, want %d-%d", tc.spec, lo, hi, tc.wantLo, tc.wantHi)
+			}
+		})
+	}
+}
+
+func TestSplitPortRange(t *testing.T) {
+	testCases := []struct {
+		name   string
+		lo, hi uint16
+		want   []portPrefix
+	}{
+		{
+			name: "all ports",
+			lo:   0, hi: 65535,
+			want: []portPrefix{{port: 0, bits: 0}},
+		},
+		{
+			name: "all but port 0",
+			lo:   1, hi: 65535,
+			want: []portPrefix{
+				{port: 1, bits: 16}, {port: 2, bits: 15}, {port: 4, bits: 14}, {port: 8, bits: 13},
+				{port: 16, bits: 12}, {port: 32, bits: 11}, {port: 64, bits: 10}, {port: 128, bits: 9},
+				{port: 256, bits: 8}, {port: 512, bits: 7}, {port: 1024, bits: 6}, {port: 2048, bits: 5},
+				{port: 4096, bits: 4}, {port: 8192, bits: 3}, {port: 16384, bits: 2}, {port: 32768, bits: 1},
+			},
+		},
+		{
+			name: "single port",
+			lo:   80, hi: 80,
+			want: []portPrefix{{port: 80, bits: 16}},
+		},
+		{
+			name: "last port",
+			lo:   65535, hi: 65535,
+			want: []portPrefix{{port: 65535, bits: 16}},
+		},
+		{
+			name: "aligned block",
+			lo:   1024, hi: 2047,
+			want: []portPrefix{{port: 1024, bits: 6}},
+		},
+		{
+			name: "upper half",
+			lo:   32768, hi: 65535,
+			want: []portPrefix{{port: 32768, bits: 1}},
+		},
+		{
+			name: "unaligned start",
+			lo:   1023, hi: 2047,
+			want: []portPrefix{{port: 1023, bits: 16}, {port: 1024, bits: 6}},
+		},
+		{
+			name: "unaligned end",
+			lo:   1024, hi: 2048,
+			want: []portPrefix{{port: 1024, bits: 6}, {port: 2048, bits: 16}},
+		},
+		{
+			name: "unaligned both ends",
+			lo:   30000, hi: 30100,
+			want: []portPrefix{
+				{port: 30000, bits: 12}, {port: 30016, bits: 10}, {port: 30080, bits: 12},
+				{port: 30096, bits: 14}, {port: 30100, bits: 16},
+			},
+		},
+		{
+			name: "two ports across an alignment",
+			lo:   7, hi: 8,
+			want: []portPrefix{{port: 7, bits: 16}, {port: 8, bits: 16}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prefixes := splitPortRange(tc.lo, tc.hi)
+			if !reflect.DeepEqual(prefixes, tc.want) {
+				t.Errorf("splitPortRange(%d, %d) = %v, want %v", tc.lo, tc.hi, prefixes, tc.want)
+			}
+			checkPortPrefixes(t, tc.lo, tc.hi, prefixes)
+		})
+	}
+}
+
+func TestSplitPortRangeCoverage(t *testing.T) {
+	bounds := []uint16{0, 1, 2, 3, 255, 256, 1000, 1023, 1024, 4095, 30000, 32767, 32768, 65534, 65535}
+	for _, lo := range bounds {
+		for _, hi := range bounds {
+			if lo > hi {
+				continue
+			}
+			checkPortPrefixes(t, lo, hi, splitPortRange(lo, hi))
+		}
+	}
+}
+
+// checkPortPrefixes verifies the prefixes are aligned, contiguous and cover exactly [lo, hi]
+func checkPortPrefixes(t *testing.T, lo, hi uint16, prefixes []portPrefix) {
+	t.Helper()
+	next := uint32(lo)
+	for _, prefix := range prefixes {
+		if prefix.bits > 16 {
+			t.Fatalf("%d-%d: prefix %d/%d longer than a port", lo, hi, prefix.port, prefix.bits)
+		}
+		size := uint32(1) << (16 - prefix.bits)
+		if uint32(prefix.port)%size != 0 {
+			t.Fatalf("%d-%d: prefix %d/%d not aligned", lo, hi, prefix.port, prefix.bits)
+		}
+		if uint32(prefix.port) != next {
+			t.Fatalf("%d-%d: prefix %d/%d does not start at %d", lo, hi, prefix.port, prefix.bits, next)
+		}
+		next += size
+	}
+	if next != uint32(hi)+1 {
+		t.Fatalf("%d-%d: prefixes end at %d", lo, hi, next-1)
+	}
+}
@@ -0,0 +1,131 @@
+package maps
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/fs"
+	"github.com/flomesh-io/xnet/pkg/xnet/util"
+)
+
+// SetRateLimit limits the new flows from the source addr to rate per second with burst,
+// the unspecified addr limits every source of the sys without its own limit.
+// Flows already established are not limited.
+func SetRateLimit(sysId SysID, addr net.IP, rate, burst uint32) error {
+	if rate == 0 {
+		return fmt.Errorf("invalid rate: %d", rate)
+	}
+	if burst == 0 {
+		burst = rate
+	}
+	rateKey, err := newRateLimitKey(sysId, addr)
+	if err != nil {
+		return err
+	}
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_RATE_LIMIT)
+	rateMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return err
+	}
+	defer rateMap.Close()
+
+	rateVal := RateLimitVal{
+		Rate:     rate,
+		Burst:    burst,
+		Interval: uint64(time.Second) / uint64(rate),
+	}
+	// keep the drops counted by the previous limit
+	if exists := new(RateLimitVal); rateMap.Lookup(rateKey, exists) == nil {
+		rateVal.Drops = exists.Drops
+	}
+	return rateMap.Update(rateKey, &rateVal, ebpf.UpdateAny)
+}
+
+// DelRateLimit removes the limit of the source addr
+func DelRateLimit(sysId SysID, addr net.IP) error {
+	rateKey, err := newRateLimitKey(sysId, addr)
+	if err != nil {
+		return err
+	}
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_RATE_LIMIT)
+	rateMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return err
+	}
+	defer rateMap.Close()
+	err = rateMap.Delete(rateKey)
+	if errors.Is(err, unix.ENOENT) {
+		return nil
+	}
+	return err
+}
+
+func GetRateLimits() (map[RateLimitKey]RateLimitVal, error) {
+	items := make(map[RateLimitKey]RateLimitVal)
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_RATE_LIMIT)
+	rateMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		// pinned by a prog without rate limit support
+		if errors.Is(err, os.ErrNotExist) {
+			return items, nil
+		}
+		return nil, err
+	}
+	defer rateMap.Close()
+	rateKey := new(RateLimitKey)
+	rateVal := new(RateLimitVal)
+	it := rateMap.Iterate()
+	for it.Next(rateKey, rateVal) {
+		items[*rateKey] = *rateVal
+	}
+	return items, it.Err()
+}
+
+func ShowRateLimits() {
+	items, err := GetRateLimits()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to list rate limits")
+	}
+	first := true
+	fmt.Println(`[`)
+	for rateKey, rateVal := range items {
+		if first {
+			first = false
+		} else {
+			fmt.Println(`,`)
+		}
+		fmt.Printf(`{"key":%s,"value":%s}`, rateKey.String(), rateVal.String())
+	}
+	fmt.Println()
+	fmt.Println(`]`)
+}
+
+func newRateLimitKey(sysId SysID, addr net.IP) (*RateLimitKey, error) {
+	rateKey := new(RateLimitKey)
+	rateKey.Sys = uint32(sysId)
+	if addr == nil || addr.IsUnspecified() {
+		return rateKey, nil
+	}
+	var err error
+	if rateKey.Addr[0], rateKey.Addr[1], rateKey.Addr[2], rateKey.Addr[3], _, err = util.IPToInt(addr); err != nil {
+		return nil, err
+	}
+	return rateKey, nil
+}
+
+func (t *RateLimitKey) String() string {
+	return fmt.Sprintf(`{"sys": "%s","addr": "%s"}`,
+		_sys_(t.Sys), _ip_(t.Addr))
+}
+
+func (t *RateLimitVal) String() string {
+	return fmt.Sprintf(`{"rate": %d,"burst": %d,"drops": %d}`,
+		t.Rate, t.Burst, t.Drops)
+}
@@ -0,0 +1,70 @@
+package maps
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/fs"
+)
+
+// GetStats returns the datapath counters summed across all cpus.
+func GetStats() (map[StatKey]StatVal, error) {
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_STATS)
+	statMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer statMap.Close()
+
+	items := make(map[StatKey]StatVal)
+	statKey := new(StatKey)
+	var cpuVals []StatVal
+	it := statMap.Iterate()
+	for it.Next(statKey, &cpuVals) {
+		statVal := StatVal{}
+		for _, cpuVal := range cpuVals {
+			statVal.Pkts += cpuVal.Pkts
+			statVal.Bytes += cpuVal.Bytes
+		}
+		items[*statKey] = statVal
+	}
+	return items, it.Err()
+}
+
+// ResetStats clears the datapath counters of the given sys.
+func ResetStats(sysId SysID) error {
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_STATS)
+	statMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return err
+	}
+	defer statMap.Close()
+
+	for reason := StatReasonPkts; reason < StatReasonMax; reason++ {
+		statKey := StatKey{Sys: uint32(sysId), Reason: uint32(reason)}
+		if err = statMap.Delete(&statKey); err != nil && !errors.Is(err, unix.ENOENT) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t StatReason) String() string {
+	if t < StatReasonMax {
+		return statReasonNames[t]
+	}
+	return ""
+}
+
+func (t *StatKey) String() string {
+	return fmt.Sprintf(`{"sys": "%s","reason": "%s"}`,
+		_sys_(t.Sys), StatReason(t.Reason).String())
+}
+
+func (t *StatVal) String() string {
+	return fmt.Sprintf(`{"pkts": %d,"bytes": %d}`, t.Pkts, t.Bytes)
+}
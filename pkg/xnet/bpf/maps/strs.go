@@ -12,6 +12,22 @@ func SysName(sysId SysID) string {
 	return _sys_(uint32(sysId))
 }
 
+func IPName(ipNb [4]uint32) string {
+	return _ip_(ipNb)
+}
+
+func PortName(port uint16) uint16 {
+	return _port_(port)
+}
+
+func ProtoName(proto uint8) string {
+	return _proto_(proto)
+}
+
+func TcDirName(tcDir uint8) string {
+	return _tc_dir_(tcDir)
+}
+
 func _sys_(sys uint32) string {
 	switch sys {
 	case uint32(SysNoop):
@@ -86,6 +102,38 @@ func _acl_(acl uint8) string {
 	}
 }
 
+func _trace_event_(event uint8) string {
+	switch event {
+	case TRACE_EVENT_ACL:
+		return "TRACE_EVENT_ACL"
+	case TRACE_EVENT_NAT:
+		return "TRACE_EVENT_NAT"
+	case TRACE_EVENT_FLOW:
+		return "TRACE_EVENT_FLOW"
+	default:
+		return ""
+	}
+}
+
+func _trans_(trans int8) string {
+	switch trans {
+	case TRANS_ERR:
+		return "TRANS_ERR"
+	case TRANS_CHS:
+		return "TRANS_CHS"
+	case TRANS_EST:
+		return "TRANS_EST"
+	case TRANS_FIN:
+		return "TRANS_FIN"
+	case TRANS_CWT:
+		return "TRANS_CWT"
+	case TRANS_NON:
+		return "TRANS_NON"
+	default:
+		return ""
+	}
+}
+
 func _flow_dir_(flowDir uint8) string {
 	switch flowDir {
 	case 0:
@@ -0,0 +1,64 @@
+package maps
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/ringbuf"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/fs"
+)
+
+// WatchTraceEvents reads the trace events emitted by the datapath for the
+// traced ips and ports, until stop is closed.
+func WatchTraceEvents(stop <-chan struct{}, handler func(*TraceEvent)) error {
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_TRACE_EVT)
+	evtMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return err
+	}
+	defer evtMap.Close()
+
+	reader, err := ringbuf.NewReader(evtMap)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	go func() {
+		<-stop
+		reader.Close()
+	}()
+
+	for {
+		record, readErr := reader.Read()
+		if readErr != nil {
+			if errors.Is(readErr, ringbuf.ErrClosed) || errors.Is(readErr, os.ErrDeadlineExceeded) {
+				return nil
+			}
+			return readErr
+		}
+		evt := new(TraceEvent)
+		if err = binary.Read(bytes.NewReader(record.RawSample), binary.LittleEndian, evt); err != nil {
+			log.Error().Err(err).Msg("fail to decode trace event")
+			continue
+		}
+		handler(evt)
+	}
+}
+
+func (t *TraceEvent) String() string {
+	return fmt.Sprintf(`{"ts": %d,"event": "%s","tc_dir": "%s","ifi": %d,"ofi": %d,`+
+		`"flow": %s,`+
+		`"acl": "%s","nf": "%s","trans": "%s",`+
+		`"xnat": {"xaddr": "%s","raddr": "%s","xport": %d,"rport": %d}}`,
+		t.Ts, _trace_event_(t.Event), _tc_dir_(t.TcDir), t.Ifi, t.Ofi,
+		(*FlowKey)(&t.Flow).String(),
+		_acl_(t.Acl), _nf_(t.Nfs), _trans_(t.Trans),
+		_ip_(t.Xaddr), _ip_(t.Raddr), _port_(t.Xport), _port_(t.Rport))
+}
@@ -21,9 +21,23 @@ type ProgVal int
 
 type NatKey FsmNatKeyT
 type NatVal FsmNatOpT
+type NatMaglevVal FsmNatMaglevT
+type NatWrrVal FsmNatWrrT
+type NatAffinityKey FsmNatAffinityKeyT
+type NatAffinityVal FsmNatAffinityT
+type NatRangeKey FsmNatRangeKeyT
+type NatRangeVal FsmNatRangeT
+type NatConnKey FsmNatConnKeyT
+type NatConnVal FsmNatConnT
 
 type AclKey FsmAclKeyT
 type AclVal FsmAclOpT
+type AclCIDRKey FsmAclCidrKeyT
+type AclRangeKey FsmAclRangeKeyT
+type AclRangeVal FsmAclRangeOpT
+
+type RateLimitKey FsmRateLimitKeyT
+type RateLimitVal FsmRateLimitT
 
 type FlowKey FsmFlowT
 type FlowTCPVal FsmFlowTOpT
@@ -44,6 +58,11 @@ type TraceIPVal FsmTrOpT
 type TracePortKey FsmTrPortT
 type TracePortVal FsmTrOpT
 
+type TraceEvent FsmTrEventT
+
+type StatKey FsmStatKeyT
+type StatVal FsmStatT
+
 type FlagT struct {
 	Flags uint64
 }
@@ -75,6 +94,21 @@ const (
 
 type Acl uint8
 
+const (
+	TRACE_EVENT_ACL  = 0
+	TRACE_EVENT_NAT  = 1
+	TRACE_EVENT_FLOW = 2
+)
+
+const (
+	TRANS_ERR = -1
+	TRANS_CHS = 0
+	TRANS_EST = 1
+	TRANS_FIN = 2
+	TRANS_CWT = 3
+	TRANS_NON = 4
+)
+
 const (
 	NF_DENY    = 0
 	NF_ALLOW   = 1
@@ -114,6 +148,7 @@ const (
 	CfgFlagOffsetTraceFlowOn
 	CfgFlagOffsetTraceByIpOn
 	CfgFlagOffsetTraceByPortOn
+	CfgFlagOffsetRejectOn
 	CfgFlagMax
 )
 
@@ -148,4 +183,62 @@ var flagNames = [CfgFlagMax]string{
 	"trace_flow_on",
 	"trace_by_ip_on",
 	"trace_by_port_on",
+	"reject_on",
+}
+
+const (
+	StatReasonPkts StatReason = iota
+	StatReasonNat
+	StatReasonRdir
+	StatReasonDeny
+	StatReasonAclDeny
+	StatReasonAclTrusted
+	StatReasonNoNatDrop
+	StatReasonNatEscape
+	StatReasonRateLimitDrop
+	StatReasonConnLimitDrop
+	StatReasonReject
+	StatReasonMax
+)
+
+type StatReason uint32
+
+var statReasonNames = [StatReasonMax]string{
+	"pkts",
+	"nat",
+	"redirect",
+	"deny",
+	"acl_deny",
+	"acl_trusted",
+	"no_nat_drop",
+	"nat_escape",
+	"rate_limit_drop",
+	"conn_limit_drop",
+	"reject",
+}
+
+const (
+	NatModeRR NatMode = iota
+	NatModeMaglev
+	NatModeMax
+)
+
+type NatMode uint8
+
+var natModeNames = [NatModeMax]string{
+	"rr",
+	"maglev",
+}
+
+const (
+	NatAffinityNone NatAffinity = iota
+	NatAffinityClientIP
+	NatAffinityMax
+)
+
+type NatAffinity uint8
+
+var natAffinityNames = [NatAffinityMax]string{
+	"none",
+	"client-ip",
 }
@@ -0,0 +1,127 @@
+package maps
+
+import (
+	"os"
+
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/fs"
+)
+
+const (
+	natWrrSize = len(NatWrrVal{}.Eps)
+
+	// NatEpDefaultWeight is the weight of endpoints added without weight
+	NatEpDefaultWeight = uint16(1)
+)
+
+func epWeight(weight uint16) int {
+	if weight == 0 {
+		return int(NatEpDefaultWeight)
+	}
+	return int(weight)
+}
+
+// newWrrTable builds the smooth weighted round-robin sequence of the active endpoints,
+// returns nil if all endpoints are active with the same weight, plain round-robin is used then.
+func newWrrTable(natVal *NatVal) *NatWrrVal {
+	var idxs, weights []int
+	uniform := true
+	for idx := 0; idx < int(natVal.EpCnt) && idx < len(natVal.Eps); idx++ {
+		ep := &natVal.Eps[idx]
+		if ep.Active == 0 {
+			uniform = false
+			continue
+		}
+		weight := epWeight(ep.Weight)
+		if len(weights) > 0 && weights[0] != weight {
+			uniform = false
+		}
+		idxs = append(idxs, idx)
+		weights = append(weights, weight)
+	}
+	if uniform || len(weights) == 0 {
+		return nil
+	}
+
+	divisor := weights[0]
+	total := 0
+	for _, weight := range weights {
+		divisor = gcd(divisor, weight)
+		total += weight
+	}
+	total /= divisor
+	for n := range weights {
+		weights[n] /= divisor
+	}
+
+	// scale down to fit the sequence, every endpoint keeps at least one slot
+	if total > natWrrSize {
+		budget := natWrrSize - len(weights)
+		scaled := 0
+		for n, weight := range weights {
+			weights[n] = max(1, weight*budget/total)
+			scaled += weights[n]
+		}
+		total = scaled
+	}
+
+	table := new(NatWrrVal)
+	table.Cnt = uint16(total)
+	current := make([]int, len(weights))
+	for slot := 0; slot < total; slot++ {
+		best := 0
+		for n, weight := range weights {
+			current[n] += weight
+			if current[n] > current[best] {
+				best = n
+			}
+		}
+		current[best] -= total
+		table.Eps[slot] = uint8(idxs[best])
+	}
+	return table
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// updateWrrEntry writes the weighted sequence of the nat entry, or deletes it if weights are uniform.
+func updateWrrEntry(natKey *NatKey, natVal *NatVal) error {
+	table := newWrrTable(natVal)
+	if natVal.EpCnt == 0 || table == nil {
+		return delWrrEntry(natKey)
+	}
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_WRR)
+	wrrMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		return err
+	}
+	defer wrrMap.Close()
+	return wrrMap.Update(natKey, table, ebpf.UpdateAny)
+}
+
+func delWrrEntry(natKey *NatKey) error {
+	pinnedFile := fs.GetPinningFile(bpf.FSM_MAP_NAME_WRR)
+	wrrMap, err := ebpf.LoadPinnedMap(pinnedFile, &ebpf.LoadPinOptions{})
+	if err != nil {
+		// pinned by a prog without weight support
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer wrrMap.Close()
+	err = wrrMap.Delete(natKey)
+	if errors.Is(err, unix.ENOENT) {
+		return nil
+	}
+	return err
+}
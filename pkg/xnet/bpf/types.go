@@ -7,7 +7,16 @@ const (
 const (
 	FSM_MAP_NAME_PROG       = `fsm_prog`
 	FSM_MAP_NAME_NAT        = `fsm_xnat`
+	FSM_MAP_NAME_MAGLEV     = `fsm_xmglv`
+	FSM_MAP_NAME_WRR        = `fsm_xwrr`
+	FSM_MAP_NAME_AFFINITY   = `fsm_xaffi`
+	FSM_MAP_NAME_NAT_RANGE  = `fsm_xnrng`
+	FSM_MAP_NAME_NAT_CONN   = `fsm_xconn`
 	FSM_MAP_NAME_ACL        = `fsm_xacl`
+	FSM_MAP_NAME_ACL_CIDR   = `fsm_xcidr`
+	FSM_MAP_NAME_ACL_RANGE  = `fsm_xarng`
+	FSM_MAP_NAME_RATE_LIMIT = `fsm_xrlim`
+	FSM_MAP_NAME_RATE_BKT   = `fsm_xrbkt`
 	FSM_MAP_NAME_TCP_FLOW   = `fsm_tflow`
 	FSM_MAP_NAME_UDP_FLOW   = `fsm_uflow`
 	FSM_MAP_NAME_TCP_OPT    = `fsm_topt`
@@ -16,12 +25,17 @@ const (
 	FSM_MAP_NAME_IFS        = `fsm_xifs`
 	FSM_MAP_NAME_TRACE_IP   = `fsm_trip`
 	FSM_MAP_NAME_TRACE_PORT = `fsm_trpt`
+	FSM_MAP_NAME_STATS      = `fsm_xstat`
+	FSM_MAP_NAME_TRACE_EVT  = `fsm_xtrc`
+	FSM_MAP_NAME_PKT        = `fsm_xpkt`
+	FSM_MAP_NAME_FLOW_OP    = `fsm_xflop`
 )
 
 const (
-	FSM_PASS_PROG_KEY = uint32(0)
-	FSM_DROP_PROG_KEY = uint32(1)
-	FSM_FLOW_PROG_KEY = uint32(2)
+	FSM_PASS_PROG_KEY   = uint32(0)
+	FSM_DROP_PROG_KEY   = uint32(1)
+	FSM_FLOW_PROG_KEY   = uint32(2)
+	FSM_REJECT_PROG_KEY = uint32(3)
 )
 
 const (
@@ -36,7 +50,8 @@ const (
 	FSM_E4LB_INGRESS_PROG_NAME = `classifier_e4lb_ingress`
 	FSM_E4LB_EGRESS_PROG_NAME  = `classifier_e4lb_egress`
 
-	FSM_PASS_PROG_NAME = `classifier_pass`
-	FSM_DROP_PROG_NAME = `classifier_drop`
-	FSM_FLOW_PROG_NAME = `classifier_flow`
+	FSM_PASS_PROG_NAME   = `classifier_pass`
+	FSM_DROP_PROG_NAME   = `classifier_drop`
+	FSM_FLOW_PROG_NAME   = `classifier_flow`
+	FSM_REJECT_PROG_NAME = `classifier_reject`
 )
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/flomesh-io/xnet/pkg/k8s/kind"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
 )
 
 // Routine which fulfills listening to proxy broadcasts
@@ -15,6 +16,8 @@ func (s *server) broadcastListener() {
 	syncPeriod := time.Second * 4
 	slidingTimer := time.NewTimer(syncPeriod)
 	defer slidingTimer.Stop()
+	reapTicker := time.NewTicker(drainReapPeriod)
+	defer reapTicker.Stop()
 
 	for {
 		select {
@@ -24,6 +27,8 @@ func (s *server) broadcastListener() {
 			slidingTimer.Reset(syncPeriod)
 		case <-slidingTimer.C:
 			s.configMeshPolicies()
+		case <-reapTicker.C:
+			s.reapDrainedEps(maps.SysMesh)
 		}
 	}
 }
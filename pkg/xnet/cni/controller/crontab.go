@@ -4,6 +4,7 @@ import (
 	"github.com/go-co-op/gocron/v2"
 
 	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+	"github.com/flomesh-io/xnet/pkg/xnet/metrics"
 )
 
 const (
@@ -94,7 +95,9 @@ func (s *server) flushIdleTCPConnTracks(sysId maps.SysID, idleSeconds, batchSize
 	var err error
 	items := batchSize
 	for items == batchSize {
-		if items, err = maps.FlushIdleTCPFlowEntries(sysId, idleSeconds, batchSize); err != nil {
+		items, err = maps.FlushIdleTCPFlowEntries(sysId, idleSeconds, batchSize)
+		metrics.FlowsFlushed.WithLabelValues(maps.SysName(sysId), `tcp`).Add(float64(items))
+		if err != nil {
 			log.Error().Err(err).Msg("failed to flush idle tcp flows")
 			break
 		}
@@ -105,7 +108,9 @@ func (s *server) flushIdleUDPConnTracks(sysId maps.SysID, idleSeconds, batchSize
 	var err error
 	items := batchSize
 	for items == batchSize {
-		if items, err = maps.FlushIdleUDPFlowEntries(sysId, idleSeconds, batchSize); err != nil {
+		items, err = maps.FlushIdleUDPFlowEntries(sysId, idleSeconds, batchSize)
+		metrics.FlowsFlushed.WithLabelValues(maps.SysName(sysId), `udp`).Add(float64(items))
+		if err != nil {
 			log.Error().Err(err).Msg("failed to flush idle tcp flows")
 			break
 		}
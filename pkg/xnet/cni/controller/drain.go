@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+)
+
+const (
+	drainReapPeriod = time.Second * 5
+
+	// drainIdleSeconds is how long the flows of a draining ep may stay silent before it is removed,
+	// much shorter than the idle timeouts of the conn track flush, as the ep is going away anyway
+	drainIdleSeconds = 10
+)
+
+// reapDrainedEps removes draining eps once their flows are idle or their deadlines are passed,
+// it runs on the policy goroutine, so that it never races the nat policies reconcile.
+func (s *server) reapDrainedEps(sysId maps.SysID) {
+	reaped, err := maps.ReapDrainedEps(sysId, drainIdleSeconds)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to reap drained eps")
+	} else if reaped > 0 {
+		log.Info().Msgf("%d drained eps removed", reaped)
+	}
+}
@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"net"
+	"time"
+
+	"github.com/mitchellh/hashstructure/v2"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+
+	"github.com/flomesh-io/xnet/pkg/constants"
+	"github.com/flomesh-io/xnet/pkg/k8s/kind"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+	"github.com/flomesh-io/xnet/pkg/xnet/health"
+	"github.com/flomesh-io/xnet/pkg/xnet/util"
+)
+
+// e4lbListener reconciles the e4lb nat entries and vip announcers on service and endpoint slice events,
+// on changes of the endpoints' neighbours and health, and of the e4lb leadership.
+func (s *server) e4lbListener() {
+	kubeEventPubSub := s.msgBroker.GetKubeEventPubSub()
+	serviceEventChan := kubeEventPubSub.Sub(
+		kind.ServiceAdded.String(), kind.ServiceUpdated.String(), kind.ServiceDeleted.String(),
+		kind.EndpointSliceAdded.String(), kind.EndpointSliceUpdated.String(), kind.EndpointSliceDeleted.String())
+	defer s.msgBroker.Unsub(kubeEventPubSub, serviceEventChan)
+
+	go s.epResolver.Watch(s.stop)
+	go s.e4lbLeader.Run(s.ctx)
+
+	var healthChan <-chan struct{}
+	if s.epHealth != nil {
+		healthChan = s.epHealth.Changed()
+	}
+
+	syncPeriod := time.Second * 2
+	slidingTimer := time.NewTimer(0)
+	defer slidingTimer.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			s.stopE4lbAnnouncers()
+			return
+		case <-serviceEventChan:
+			slidingTimer.Reset(syncPeriod)
+		case <-s.epResolver.Changed():
+			slidingTimer.Reset(syncPeriod)
+		case <-s.e4lbLeader.Changed():
+			slidingTimer.Reset(0)
+		case <-healthChan:
+			slidingTimer.Reset(0)
+		case <-slidingTimer.C:
+			s.allocE4lbVips()
+			s.configE4lbPolicies()
+			s.syncE4lbAnnouncers()
+		}
+	}
+}
+
+// configE4lbPolicies translates LoadBalancer ingress ips and external ips of services
+// into e4lb nat entries, whose endpoints are the ready backends.
+// The e4lb nat entries are owned by the controller, stale ones are deleted.
+func (s *server) configE4lbPolicies() {
+	natVals := make(map[maps.NatKey]*maps.NatVal)
+
+	for _, svc := range s.kubeController.ListServices() {
+		// vips of other load balancer classes are served by their own controllers
+		if svc.Spec.Type == corev1.ServiceTypeLoadBalancer && !s.isE4lbService(svc) {
+			continue
+		}
+		vips := getServiceVips(svc)
+		if len(vips) == 0 {
+			continue
+		}
+
+		natMode := maps.NatModeRR
+		if modeName, exists := svc.Annotations[constants.E4lbModeAnnotation]; exists {
+			var err error
+			if natMode, err = maps.ParseNatMode(modeName); err != nil {
+				log.Error().Err(err).Msgf("service %s", getServiceKey(svc))
+				natMode = maps.NatModeRR
+			}
+		}
+
+		natAffinity, natAffinityTimeout := getServiceAffinity(svc)
+
+		endpointSlices := s.kubeController.ListServiceEndpointSlices(svc)
+		for _, svcPort := range svc.Spec.Ports {
+			proto, supported := corev1Protos[svcPort.Protocol]
+			if !supported {
+				continue
+			}
+
+			for _, vip := range vips {
+				natKey := maps.NatKey{}
+				natKey.Sys = uint32(maps.SysE4lb)
+				var err error
+				if natKey.Daddr[0], natKey.Daddr[1], natKey.Daddr[2], natKey.Daddr[3], natKey.V6, err = util.IPToInt(vip); err != nil {
+					continue
+				}
+				natKey.Dport = util.HostToNetShort(uint16(svcPort.Port))
+				natKey.Proto = uint8(l4Protos[proto])
+				natKey.TcDir = uint8(maps.TC_DIR_IGR)
+
+				natVal := new(maps.NatVal)
+				natVal.Mode = uint8(natMode)
+				natVal.SetAffinity(natAffinity, natAffinityTimeout)
+				for _, eps := range endpointSlices {
+					epPort, found := getEndpointSlicePort(eps, svcPort)
+					if !found {
+						continue
+					}
+					for _, ep := range eps.Endpoints {
+						if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+							continue
+						}
+						for _, epAddr := range ep.Addresses {
+							s.addE4lbEp(natVal, natKey.Proto, net.ParseIP(epAddr), epPort, vip)
+						}
+					}
+				}
+				natVals[natKey] = natVal
+			}
+		}
+	}
+
+	for natKey, natVal := range natVals {
+		chash, _ := hashstructure.Hash(natVal, hashstructure.FormatV2,
+			&hashstructure.HashOptions{
+				ZeroNil:         true,
+				IgnoreZeroValue: true,
+				SlicesAsSets:    true,
+			})
+		if hash, exists := s.e4lbNatHashes[natKey]; exists && hash == chash {
+			continue
+		}
+		if err := maps.AddNatEntry(maps.SysE4lb, &natKey, natVal); err != nil {
+			log.Error().Err(err).Msg(natKey.String())
+			continue
+		}
+		s.e4lbNatHashes[natKey] = chash
+	}
+
+	// only the entries programmed by this controller are removed, the ones added
+	// through the cli or by other controllers are left untouched
+	for natKey := range s.e4lbNatHashes {
+		if _, exists := natVals[natKey]; exists {
+			continue
+		}
+		if err := maps.DelNatEntry(maps.SysE4lb, &natKey); err != nil {
+			log.Error().Err(err).Msg(natKey.String())
+			continue
+		}
+		delete(s.e4lbNatHashes, natKey)
+	}
+}
+
+func (s *server) addE4lbEp(natVal *maps.NatVal, proto uint8, epAddr net.IP, epPort uint16, vip net.IP) {
+	if epAddr == nil || (epAddr.To4() == nil) != (vip.To4() == nil) {
+		return
+	}
+	hop, err := s.epResolver.Resolve(epAddr)
+	if err != nil {
+		log.Error().Err(err).Msgf(`fail to resolve e4lb ep: %s`, epAddr)
+		return
+	}
+	// unhealthy eps are kept inactive, the health checker activates them once recovered
+	active := s.epHealth == nil || s.epHealth.Healthy(health.NewTarget(proto, epAddr, epPort))
+	if added, addErr := natVal.AddEp(epAddr, epPort, hop.Rmac, hop.Ofi, hop.Oflags, hop.Omac, maps.NatEpDefaultWeight, active); addErr != nil {
+		log.Error().Err(addErr).Msgf(`fail to add e4lb ep: %s:%d`, epAddr, epPort)
+	} else if !added {
+		log.Error().Msgf(`too many e4lb eps, ignore: %s:%d`, epAddr, epPort)
+	}
+}
+
+// getServiceVips returns LoadBalancer ingress ips and external ips of the service
+func getServiceVips(svc *corev1.Service) []net.IP {
+	var vips []net.IP
+	if svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if vip := net.ParseIP(ingress.IP); vip != nil {
+				vips = append(vips, vip)
+			}
+		}
+	}
+	return append(vips, getServiceExternalIPs(svc)...)
+}
+
+// getServiceExternalIPs returns the external ips of the service
+func getServiceExternalIPs(svc *corev1.Service) []net.IP {
+	var vips []net.IP
+	for _, externalIP := range svc.Spec.ExternalIPs {
+		if vip := net.ParseIP(externalIP); vip != nil {
+			vips = append(vips, vip)
+		}
+	}
+	return vips
+}
+
+// getServiceAffinity returns the client-ip affinity and its timeout of the service
+func getServiceAffinity(svc *corev1.Service) (maps.NatAffinity, uint32) {
+	if svc.Spec.SessionAffinity != corev1.ServiceAffinityClientIP {
+		return maps.NatAffinityNone, 0
+	}
+	timeout := maps.NatAffinityDefaultTimeout
+	if cfg := svc.Spec.SessionAffinityConfig; cfg != nil && cfg.ClientIP != nil && cfg.ClientIP.TimeoutSeconds != nil {
+		timeout = uint32(*cfg.ClientIP.TimeoutSeconds)
+	}
+	return maps.NatAffinityClientIP, timeout
+}
+
+// getEndpointSlicePort returns the endpoint port matching the service port
+func getEndpointSlicePort(eps *discoveryv1.EndpointSlice, svcPort corev1.ServicePort) (uint16, bool) {
+	for _, port := range eps.Ports {
+		if port.Port == nil {
+			continue
+		}
+		if port.Name != nil && *port.Name != svcPort.Name {
+			continue
+		}
+		if port.Name == nil && len(svcPort.Name) > 0 {
+			continue
+		}
+		if port.Protocol != nil && *port.Protocol != svcPort.Protocol {
+			continue
+		}
+		return uint16(*port.Port), true
+	}
+	return 0, false
+}
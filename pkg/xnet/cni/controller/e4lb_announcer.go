@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/e4lb"
+)
+
+// syncE4lbAnnouncers runs a leader-elected announcer per vip, and stops those of released vips
+func (s *server) syncE4lbAnnouncers() {
+	vips := make(map[string]net.IP)
+	for _, svc := range s.kubeController.ListServices() {
+		// vips of other load balancer classes are announced by their own controllers
+		if svc.Spec.Type == corev1.ServiceTypeLoadBalancer && !s.isE4lbService(svc) {
+			continue
+		}
+		for _, vip := range getServiceVips(svc) {
+			if v4 := vip.To4() != nil; (v4 && !s.enableE4lbIPv4) || (!v4 && !s.enableE4lbIPv6) {
+				continue
+			}
+			vips[vip.String()] = vip
+		}
+	}
+
+	for vipStr, cancel := range s.e4lbAnnouncers {
+		if _, exists := vips[vipStr]; !exists {
+			cancel()
+			delete(s.e4lbAnnouncers, vipStr)
+		}
+	}
+
+	for vipStr, vip := range vips {
+		if _, exists := s.e4lbAnnouncers[vipStr]; exists {
+			continue
+		}
+		ctx, cancel := context.WithCancel(s.ctx)
+		announcer := e4lb.NewVipAnnouncer(vip, s.kubeClient, s.fsmNamespace, os.Getenv("NODE_NAME"))
+		go announcer.Run(ctx)
+		s.e4lbAnnouncers[vipStr] = cancel
+	}
+}
+
+func (s *server) stopE4lbAnnouncers() {
+	for vipStr, cancel := range s.e4lbAnnouncers {
+		cancel()
+		delete(s.e4lbAnnouncers, vipStr)
+	}
+}
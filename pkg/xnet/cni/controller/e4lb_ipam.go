@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/flomesh-io/xnet/pkg/constants"
+	"github.com/flomesh-io/xnet/pkg/xnet/e4lb"
+)
+
+// allocE4lbVips assigns vips of the pools to LoadBalancer services and writes them back to the service status.
+// The allocation is rebuilt from the service status on each run, so it is persisted by the api server,
+// and vips of deleted services are released implicitly. Older services win vip conflicts.
+// Only the e4lb leader writes the status, decisions are deterministic,
+// so that a new leader keeps the vips assigned by the previous one.
+func (s *server) allocE4lbVips() {
+	if len(s.e4lbVipPools) == 0 || !s.e4lbLeader.IsLeader() {
+		return
+	}
+
+	var services, otherServices []*corev1.Service
+	for _, svc := range s.kubeController.ListServices() {
+		if s.isE4lbService(svc) {
+			services = append(services, svc)
+		} else {
+			otherServices = append(otherServices, svc)
+		}
+	}
+	slices.SortFunc(services, func(svc1, svc2 *corev1.Service) int {
+		if c := svc1.CreationTimestamp.Compare(svc2.CreationTimestamp.Time); c != 0 {
+			return c
+		}
+		return strings.Compare(getServiceKey(svc1), getServiceKey(svc2))
+	})
+
+	allocator := e4lb.NewVipAllocator(s.e4lbVipPools)
+	svcVips := make(map[string][]netip.Addr)
+
+	// the vips in use by other services, as external ips or ingress ips of
+	// other load balancer classes, are never handed out
+	for _, svc := range otherServices {
+		reserveServiceVips(allocator, svc, getServiceVips(svc))
+	}
+	for _, svc := range services {
+		reserveServiceVips(allocator, svc, getServiceExternalIPs(svc))
+	}
+
+	// keep the vips already assigned
+	for _, svc := range services {
+		svcKey := getServiceKey(svc)
+		pool := allocator.Pool(svc.Annotations[constants.E4lbVipPoolAnnotation])
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			vip, err := netip.ParseAddr(ingress.IP)
+			if err != nil || pool == nil || !pool.Contains(vip) {
+				continue
+			}
+			vip = vip.Unmap()
+			if requested := getRequestedVip(svc, vip.Is6()); requested.IsValid() && requested != vip {
+				continue
+			}
+			if err = allocator.Assign(svcKey, vip); err != nil {
+				log.Error().Err(err).Msgf("vip conflict of service %s", svcKey)
+				continue
+			}
+			svcVips[svcKey] = append(svcVips[svcKey], vip)
+		}
+	}
+
+	// allocate the missing vips
+	for _, svc := range services {
+		svcKey := getServiceKey(svc)
+		poolName := svc.Annotations[constants.E4lbVipPoolAnnotation]
+		pool := allocator.Pool(poolName)
+		if pool == nil {
+			log.Error().Msgf("vip pool %s of service %s not found", poolName, svcKey)
+			continue
+		}
+		for _, family := range getServiceIPFamilies(svc) {
+			v6 := family == corev1.IPv6Protocol
+			if slices.ContainsFunc(svcVips[svcKey], func(vip netip.Addr) bool { return vip.Is6() == v6 }) {
+				continue
+			}
+			if requested := getRequestedVip(svc, v6); requested.IsValid() {
+				if !pool.Contains(requested) {
+					log.Error().Msgf("requested vip %s of service %s is out of pool %s", requested, svcKey, pool.Name)
+					continue
+				}
+				if err := allocator.Assign(svcKey, requested); err != nil {
+					log.Error().Err(err).Msgf("vip conflict of service %s", svcKey)
+					continue
+				}
+				svcVips[svcKey] = append(svcVips[svcKey], requested)
+				continue
+			}
+			vip, err := allocator.Allocate(svcKey, pool, v6)
+			if err != nil {
+				log.Error().Err(err).Msgf("fail to allocate vip for service %s", svcKey)
+				continue
+			}
+			svcVips[svcKey] = append(svcVips[svcKey], vip)
+		}
+		s.updateE4lbServiceStatus(svc, svcVips[svcKey])
+	}
+}
+
+// reserveServiceVips marks the vips as owned by the service,
+// vips shared between services are kept by the first one.
+func reserveServiceVips(allocator *e4lb.VipAllocator, svc *corev1.Service, vips []net.IP) {
+	for _, ip := range vips {
+		vip, ok := netip.AddrFromSlice(ip)
+		if !ok {
+			continue
+		}
+		if err := allocator.Assign(getServiceKey(svc), vip); err != nil {
+			log.Debug().Err(err).Msgf("vip of service %s", getServiceKey(svc))
+		}
+	}
+}
+
+func (s *server) updateE4lbServiceStatus(svc *corev1.Service, vips []netip.Addr) {
+	var ingresses []corev1.LoadBalancerIngress
+	for _, vip := range vips {
+		ingresses = append(ingresses, corev1.LoadBalancerIngress{IP: vip.String()})
+	}
+	if slices.EqualFunc(ingresses, svc.Status.LoadBalancer.Ingress, func(i1, i2 corev1.LoadBalancerIngress) bool {
+		return i1.IP == i2.IP
+	}) {
+		return
+	}
+
+	svcCopy := svc.DeepCopy()
+	svcCopy.Status.LoadBalancer.Ingress = ingresses
+	if _, err := s.kubeClient.CoreV1().Services(svc.Namespace).UpdateStatus(s.ctx, svcCopy, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) {
+			log.Debug().Err(err).Msgf("service %s status updated by others", getServiceKey(svc))
+			return
+		}
+		log.Error().Err(err).Msgf("fail to update status of service %s", getServiceKey(svc))
+		return
+	}
+	log.Info().Msgf("service %s vips: %v", getServiceKey(svc), vips)
+}
+
+// isE4lbService returns whether the service is a LoadBalancer of the configured load balancer class,
+// services without a class are left to the cloud provider's load balancer.
+func (s *server) isE4lbService(svc *corev1.Service) bool {
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return false
+	}
+	return svc.Spec.LoadBalancerClass != nil && *svc.Spec.LoadBalancerClass == s.e4lbLbClass
+}
+
+func getServiceKey(svc *corev1.Service) string {
+	return fmt.Sprintf(`%s/%s`, svc.Namespace, svc.Name)
+}
+
+func getServiceIPFamilies(svc *corev1.Service) []corev1.IPFamily {
+	if len(svc.Spec.IPFamilies) == 0 {
+		return []corev1.IPFamily{corev1.IPv4Protocol}
+	}
+	return svc.Spec.IPFamilies
+}
+
+// getRequestedVip returns the vip of the family requested by annotation or spec.loadBalancerIP
+func getRequestedVip(svc *corev1.Service, v6 bool) netip.Addr {
+	requests := strings.Split(svc.Annotations[constants.E4lbVipAnnotation], `,`)
+	requests = append(requests, svc.Spec.LoadBalancerIP)
+	for _, request := range requests {
+		if vip, err := netip.ParseAddr(strings.TrimSpace(request)); err == nil && vip.Unmap().Is6() == v6 {
+			return vip.Unmap()
+		}
+	}
+	return netip.Addr{}
+}
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/containernetworking/cni/pkg/skel"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
 )
 
 func (s *server) PodCreated(w http.ResponseWriter, req *http.Request) {
@@ -49,3 +52,25 @@ func (s *server) PodDeleted(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("ok"))
 }
+
+// TCPFlowsFlush flushes idle tcp flows for the cli, so that the released conn counts
+// are only written by this process.
+func (s *server) TCPFlowsFlush(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	sysId, sysErr := strconv.ParseUint(query.Get("sys"), 10, 32)
+	idleSeconds, idleErr := strconv.Atoi(query.Get("idle_seconds"))
+	batchSize, batchErr := strconv.Atoi(query.Get("batch_size"))
+	if sysErr != nil || idleErr != nil || batchErr != nil || batchSize <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("invalid sys, idle_seconds or batch_size"))
+		return
+	}
+	items, err := maps.FlushIdleTCPFlowEntries(maps.SysID(sysId), idleSeconds, batchSize)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(strconv.Itoa(items)))
+}
@@ -45,9 +45,7 @@ func (s *server) CmdAdd(args *skel.CmdArgs) (err error) {
 
 	log.Debug().Msgf("CmdAdd %s/%s", pod, namespace)
 
-	nsPath := strings.Replace(args.Netns, volume.SysRun.HostPath, volume.SysRun.MountPath, 1)
-	nsPath = strings.Replace(nsPath, volume.SysProc.HostPath, volume.SysProc.MountPath, 1)
-	netNS, err := ns.GetNS(nsPath)
+	netNS, err := ns.GetNS(getMountedNetns(args.Netns))
 	if err != nil {
 		log.Error().Msgf("get ns %s error", args.Netns)
 		return err
@@ -71,14 +69,75 @@ func (s *server) CmdAdd(args *skel.CmdArgs) (err error) {
 	return err
 }
 
-func (s *server) CmdDelete(args *skel.CmdArgs) error {
-	//k8sArgs := cli.K8sArgs{}
-	//if err := types.LoadArgs(args.Args, &k8sArgs); err != nil {
-	//	return err
-	//}
+func (s *server) CmdDelete(args *skel.CmdArgs) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			msg := fmt.Sprintf("xcni panic during cmdDelete: %v\n%v", e, string(debug.Stack()))
+			if err != nil {
+				msg = fmt.Sprintf("%s: %v", msg, err)
+			}
+			err = fmt.Errorf("%s", msg)
+		}
+		if err != nil {
+			log.Error().Msgf("xcni cmdDelete error: %v", err)
+		}
+	}()
 
-	//namespace := string(k8sArgs.K8S_POD_NAME)
-	//pod := string(k8sArgs.K8S_POD_NAMESPACE)
-	//log.Debug().Msgf("CmdDelete %s/%s", pod, namespace)
+	k8sArgs := plugin.K8sArgs{}
+	if err = types.LoadArgs(args.Args, &k8sArgs); err != nil {
+		return err
+	}
+
+	podName := string(k8sArgs.K8S_POD_NAME)
+	podNamespace := string(k8sArgs.K8S_POD_NAMESPACE)
+	log.Debug().Msgf("CmdDelete %s/%s", podNamespace, podName)
+
+	podAddrs := s.findPodAddrs(args, &k8sArgs, podName, podNamespace)
+	if len(podAddrs) == 0 {
+		log.Debug().Msgf("CmdDelete %s/%s: pod addr not found", podNamespace, podName)
+		return nil
+	}
+
+	for _, podAddr := range podAddrs {
+		s.podNetns.forget(podAddr.String())
+	}
+
+	// the flows are purged and the policies resynced by the daemon
+	s.podPurges.add(podAddrs...)
 	return nil
 }
+
+// findPodAddrs resolves the addrs of a pod being deleted, from the cni args,
+// the pod cache or the netns index in that order.
+func (s *server) findPodAddrs(args *skel.CmdArgs, k8sArgs *plugin.K8sArgs, podName, podNamespace string) []net.IP {
+	if k8sArgs.IP != nil {
+		return []net.IP{k8sArgs.IP}
+	}
+
+	if pod := s.kubeController.GetPod(podName, podNamespace); pod != nil {
+		var podAddrs []net.IP
+		for _, podIP := range pod.Status.PodIPs {
+			if podAddr := net.ParseIP(podIP.IP); podAddr != nil {
+				podAddrs = append(podAddrs, podAddr)
+			}
+		}
+		if len(podAddrs) > 0 {
+			return podAddrs
+		}
+	}
+
+	var podAddrs []net.IP
+	if len(args.Netns) > 0 {
+		for _, addrStr := range s.podNetns.lookupByNetns(getMountedNetns(args.Netns)) {
+			if podAddr := net.ParseIP(addrStr); podAddr != nil {
+				podAddrs = append(podAddrs, podAddr)
+			}
+		}
+	}
+	return podAddrs
+}
+
+func getMountedNetns(netns string) string {
+	nsPath := strings.Replace(netns, volume.SysRun.HostPath, volume.SysRun.MountPath, 1)
+	return strings.Replace(nsPath, volume.SysProc.HostPath, volume.SysProc.MountPath, 1)
+}
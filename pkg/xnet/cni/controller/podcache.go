@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/ns"
+	"github.com/flomesh-io/xnet/pkg/xnet/volume"
+)
+
+type podAttachState string
+
+const (
+	podAttachUnknown  podAttachState = `unknown`
+	podAttachAttached podAttachState = `attached`
+	podAttachDetached podAttachState = `detached`
+)
+
+// podNetns is an indexed address living in a pod's netns
+type podNetns struct {
+	Addr    string         `json:"addr"`
+	Pod     string         `json:"pod,omitempty"`
+	Netns   string         `json:"netns"`
+	Iface   string         `json:"iface"`
+	IfIndex int            `json:"ifindex"`
+	HwAddr  string         `json:"mac"`
+	State   podAttachState `json:"state"`
+
+	hwAddr net.HardwareAddr
+}
+
+// podNetnsCache indexes pod addrs to their netns, refreshed incrementally:
+// only netns not indexed yet are entered, vanished netns are dropped.
+// After invalidate, the next refresh enters every netns again.
+type podNetnsCache struct {
+	mu      sync.RWMutex
+	byAddr  map[string]*podNetns
+	byNetns map[string][]string
+	stale   bool
+}
+
+func newPodNetnsCache() *podNetnsCache {
+	return &podNetnsCache{
+		byAddr:  make(map[string]*podNetns),
+		byNetns: make(map[string][]string),
+	}
+}
+
+// refresh indexes new netns and drops vanished ones.
+func (c *podNetnsCache) refresh() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rescan := c.stale
+	c.stale = false
+
+	existsNetns := make(map[string]bool)
+	for _, netnsDir := range volume.Netns {
+		rd, err := os.ReadDir(netnsDir)
+		if err != nil {
+			log.Debug().Err(err).Msg(netnsDir)
+			continue
+		}
+		for _, fi := range rd {
+			nsName, inode := ns.GetInode(fi, netnsDir)
+			existsNetns[inode] = true
+			if _, indexed := c.byNetns[inode]; indexed && !rescan {
+				continue
+			}
+			entries, nsErr := scanNetns(inode)
+			if nsErr != nil {
+				log.Debug().Err(nsErr).Msg(nsName)
+				continue
+			}
+			// the pod's addr may not be configured yet, retry on next refresh
+			if len(entries) == 0 {
+				continue
+			}
+			oldAddrs := c.byNetns[inode]
+			delete(c.byNetns, inode)
+			newAddrs := make(map[string]bool, len(entries))
+			for _, entry := range entries {
+				newAddrs[entry.Addr] = true
+				if stale, exists := c.byAddr[entry.Addr]; exists {
+					// the attach state still holds while the addr lives on the same iface
+					if stale.Netns == entry.Netns && stale.IfIndex == entry.IfIndex {
+						entry.Pod = stale.Pod
+						entry.State = stale.State
+					}
+					c.dropAddr(stale.Netns, entry.Addr)
+				}
+				c.byAddr[entry.Addr] = entry
+				c.byNetns[inode] = append(c.byNetns[inode], entry.Addr)
+			}
+			for _, addr := range oldAddrs {
+				if !newAddrs[addr] {
+					delete(c.byAddr, addr)
+				}
+			}
+		}
+	}
+
+	for inode, addrs := range c.byNetns {
+		if existsNetns[inode] {
+			continue
+		}
+		for _, addr := range addrs {
+			if entry, exists := c.byAddr[addr]; exists && entry.Netns == inode {
+				delete(c.byAddr, addr)
+			}
+		}
+		delete(c.byNetns, inode)
+	}
+}
+
+func (c *podNetnsCache) dropAddr(inode, addr string) {
+	addrs := c.byNetns[inode]
+	for i, a := range addrs {
+		if a == addr {
+			c.byNetns[inode] = append(addrs[:i], addrs[i+1:]...)
+			break
+		}
+	}
+}
+
+// invalidate makes the next refresh rescan every netns, the attach states
+// of the addrs still living on the same iface are kept.
+func (c *podNetnsCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stale = true
+}
+
+// lookup returns a copy of the indexed entry of the addr.
+func (c *podNetnsCache) lookup(addr string) (podNetns, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if entry, exists := c.byAddr[addr]; exists {
+		return *entry, true
+	}
+	return podNetns{}, false
+}
+
+// lookupByNetns returns the addrs indexed in the netns.
+func (c *podNetnsCache) lookupByNetns(inode string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string(nil), c.byNetns[inode]...)
+}
+
+// forget drops the indexed entry of the addr and its netns.
+func (c *podNetnsCache) forget(addr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, exists := c.byAddr[addr]; exists {
+		delete(c.byAddr, addr)
+		c.dropAddr(entry.Netns, addr)
+		if len(c.byNetns[entry.Netns]) == 0 {
+			delete(c.byNetns, entry.Netns)
+		}
+	}
+}
+
+func (c *podNetnsCache) setState(addr, pod string, state podAttachState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, exists := c.byAddr[addr]; exists {
+		entry.Pod = pod
+		entry.State = state
+	}
+}
+
+func (c *podNetnsCache) list() []podNetns {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entries := make([]podNetns, 0, len(c.byAddr))
+	for _, entry := range c.byAddr {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Addr < entries[j].Addr
+	})
+	return entries
+}
+
+func scanNetns(inode string) ([]*podNetns, error) {
+	netNS, err := ns.GetNS(inode)
+	if err != nil {
+		return nil, err
+	}
+	defer netNS.Close()
+
+	var entries []*podNetns
+	err = netNS.Do(func(_ ns.NetNS) error {
+		ifaces, ifaceErr := net.Interfaces()
+		if ifaceErr != nil {
+			return ifaceErr
+		}
+		for _, iface := range ifaces {
+			if (iface.Flags&net.FlagLoopback) != 0 || (iface.Flags&net.FlagUp) == 0 {
+				continue
+			}
+			addrs, addrErr := iface.Addrs()
+			if addrErr != nil {
+				continue
+			}
+			for _, addr := range addrs {
+				addrStr := addr.String()
+				addrStr = addrStr[0:strings.Index(addrStr, `/`)]
+				entries = append(entries, &podNetns{
+					Addr:    addrStr,
+					Netns:   inode,
+					Iface:   iface.Name,
+					IfIndex: iface.Index,
+					HwAddr:  iface.HardwareAddr.String(),
+					State:   podAttachUnknown,
+					hwAddr:  iface.HardwareAddr,
+				})
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func (s *server) PodNetnsList(w http.ResponseWriter, _ *http.Request) {
+	bs, err := json.MarshalIndent(s.podNetns.list(), "", " ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(bs)
+}
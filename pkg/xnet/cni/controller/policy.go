@@ -5,9 +5,11 @@ import (
 	"strings"
 
 	"github.com/mitchellh/hashstructure/v2"
+	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+	"github.com/flomesh-io/xnet/pkg/xnet/metrics"
 	"github.com/flomesh-io/xnet/pkg/xnet/util"
 )
 
@@ -18,70 +20,52 @@ var (
 		corev1.ProtocolUDP:  corev1.ProtocolUDP,
 	}
 
+	l4Protos = map[corev1.Protocol]maps.L4Proto{
+		corev1.ProtocolTCP: maps.IPPROTO_TCP,
+		corev1.ProtocolUDP: maps.IPPROTO_UDP,
+	}
+
+	supportedV6s    = []uint8{0, 1}
 	supportedProtos = []corev1.Protocol{corev1.ProtocolTCP, corev1.ProtocolUDP}
 	supportedTcdirs = []maps.TcDir{maps.TC_DIR_IGR, maps.TC_DIR_EGR}
 
-	natPolicies map[corev1.Protocol]map[maps.TcDir]*NatPolicy = nil
+	natPolicies map[uint8]map[corev1.Protocol]map[maps.TcDir]*NatPolicy = nil
 )
 
 type NatPolicy struct {
-	hash   uint64
-	natKey *maps.NatKey
-	natVal *maps.NatVal
+	hash      uint64
+	natKey    *maps.NatKey
+	natVal    *maps.NatVal
+	existsVal *maps.NatVal
 }
 
 func init() {
-	tcpIgrPolicy := new(NatPolicy)
-	tcpIgrNatKey := new(maps.NatKey)
-	tcpIgrNatKey.TcDir = uint8(maps.TC_DIR_IGR)
-	tcpIgrNatKey.Proto = uint8(maps.IPPROTO_TCP)
-	tcpIgrNatKey.Daddr = [4]uint32{0, 0, 0, 0}
-	tcpIgrNatKey.Dport = util.HostToNetShort(0)
-	tcpIgrNatKey.V6 = 0
-	tcpIgrPolicy.natKey = tcpIgrNatKey
-
-	tcpEgrPolicy := new(NatPolicy)
-	tcpEgrNatKey := new(maps.NatKey)
-	tcpEgrNatKey.TcDir = uint8(maps.TC_DIR_EGR)
-	tcpEgrNatKey.Proto = uint8(maps.IPPROTO_TCP)
-	tcpEgrNatKey.Daddr = [4]uint32{0, 0, 0, 0}
-	tcpEgrNatKey.Dport = util.HostToNetShort(0)
-	tcpEgrNatKey.V6 = 0
-	tcpEgrPolicy.natKey = tcpEgrNatKey
-
-	udpIgrPolicy := new(NatPolicy)
-	udpIgrNatKey := new(maps.NatKey)
-	udpIgrNatKey.TcDir = uint8(maps.TC_DIR_IGR)
-	udpIgrNatKey.Proto = uint8(maps.IPPROTO_UDP)
-	udpIgrNatKey.Daddr = [4]uint32{0, 0, 0, 0}
-	udpIgrNatKey.Dport = util.HostToNetShort(0)
-	udpIgrNatKey.V6 = 0
-	udpIgrPolicy.natKey = udpIgrNatKey
-
-	udpEgrPolicy := new(NatPolicy)
-	udpEgrNatKey := new(maps.NatKey)
-	udpEgrNatKey.TcDir = uint8(maps.TC_DIR_EGR)
-	udpEgrNatKey.Proto = uint8(maps.IPPROTO_UDP)
-	udpEgrNatKey.Daddr = [4]uint32{0, 0, 0, 0}
-	udpEgrNatKey.Dport = util.HostToNetShort(0)
-	udpEgrNatKey.V6 = 0
-	udpEgrPolicy.natKey = udpEgrNatKey
-
-	natPolicies = map[corev1.Protocol]map[maps.TcDir]*NatPolicy{
-		corev1.ProtocolTCP: {
-			maps.TC_DIR_IGR: tcpIgrPolicy,
-			maps.TC_DIR_EGR: tcpEgrPolicy,
-		},
-		corev1.ProtocolUDP: {
-			maps.TC_DIR_IGR: udpIgrPolicy,
-			maps.TC_DIR_EGR: udpEgrPolicy,
-		},
+	natPolicies = make(map[uint8]map[corev1.Protocol]map[maps.TcDir]*NatPolicy)
+	for _, v6 := range supportedV6s {
+		natPolicies[v6] = make(map[corev1.Protocol]map[maps.TcDir]*NatPolicy)
+		for _, proto := range supportedProtos {
+			natPolicies[v6][proto] = make(map[maps.TcDir]*NatPolicy)
+			for _, tcdir := range supportedTcdirs {
+				natKey := new(maps.NatKey)
+				natKey.TcDir = uint8(tcdir)
+				natKey.Proto = uint8(l4Protos[proto])
+				natKey.Daddr = [4]uint32{0, 0, 0, 0}
+				natKey.Dport = util.HostToNetShort(0)
+				natKey.V6 = v6
+				natPolicies[v6][proto][tcdir] = &NatPolicy{natKey: natKey}
+			}
+		}
 	}
 }
 
 func (s *server) configMeshPolicies() {
+	aclTimer := prometheus.NewTimer(metrics.PolicyReconcileDuration.WithLabelValues(`acl`))
 	s.configMeshAclPolicies()
+	aclTimer.ObserveDuration()
+
+	natTimer := prometheus.NewTimer(metrics.PolicyReconcileDuration.WithLabelValues(`nat`))
 	s.configMeshNatPolicies()
+	natTimer.ObserveDuration()
 }
 
 func (s *server) configMeshAclPolicies() {
@@ -109,13 +93,21 @@ func (s *server) configMeshAclPolicies() {
 }
 
 func (s *server) configMeshNatPolicies() {
-	for _, proto := range supportedProtos {
-		for _, tcdir := range supportedTcdirs {
-			natPolicies[proto][tcdir].natVal = new(maps.NatVal)
+	for _, v6 := range supportedV6s {
+		for _, proto := range supportedProtos {
+			for _, tcdir := range supportedTcdirs {
+				policy := natPolicies[v6][proto][tcdir]
+				policy.natVal = new(maps.NatVal)
+				if existsVal, err := maps.GetNatEntry(maps.SysMesh, policy.natKey); err == nil {
+					policy.existsVal = existsVal
+				} else {
+					policy.existsVal = nil
+				}
+			}
 		}
 	}
 
-	trustedAddrs := make(map[uint32]map[uint16]uint8)
+	trustedAddrs := make(map[[4]uint32]map[uint16]uint8)
 	existsAcls := maps.GetAclEntries()
 
 	pods := s.kubeController.ListSidecarPods()
@@ -124,38 +116,41 @@ func (s *server) configMeshNatPolicies() {
 			continue
 		}
 
-		podAddr := net.ParseIP(pod.Status.PodIP)
-		if podAddr.To4() == nil || podAddr.IsUnspecified() || podAddr.IsMulticast() {
-			log.Error().Msgf(`invalid sidecar's addr: %s'`, pod.Status.PodIP)
-			continue
-		}
+		for _, podAddr := range getPodAddrs(pod) {
+			if podAddr == nil || podAddr.IsUnspecified() || podAddr.IsMulticast() {
+				log.Error().Msgf(`invalid sidecar's addr: %s'`, podAddr)
+				continue
+			}
 
-		podMac, found := s.findHwAddrByPodIP(pod.Status.PodIP)
-		if !found {
-			log.Error().Msgf(`fail to get sidecar[%s]'s mac addr'`, pod.Status.PodIP)
-			continue
-		}
+			podMac, found := s.findHwAddrByPodIP(podAddr.String())
+			if !found {
+				log.Error().Msgf(`fail to get sidecar[%s]'s mac addr'`, podAddr)
+				continue
+			}
 
-		podAddrNb, _ := util.IPv4ToInt(podAddr)
+			var podAddrNb [4]uint32
+			var v6 uint8
+			podAddrNb[0], podAddrNb[1], podAddrNb[2], podAddrNb[3], v6, _ = util.IPToInt(podAddr)
 
-		trustedAddrs[podAddrNb] = map[uint16]uint8{
-			util.HostToNetShort(0): uint8(maps.ACL_TRUSTED),
-		}
+			trustedAddrs[podAddrNb] = map[uint16]uint8{
+				util.HostToNetShort(0): uint8(maps.ACL_TRUSTED),
+			}
 
-		for _, c := range pod.Spec.Containers {
-			for _, port := range c.Ports {
-				if port.ContainerPort > 0 {
-					portLe := uint16(port.ContainerPort)
-					portBe := util.HostToNetShort(portLe)
-					if s.isTargetPort(port, s.meshFilterPortInbound) {
-						trustedAddrs[podAddrNb][portBe] = uint8(maps.ACL_AUDIT)
-						natPolicies[corev1Protos[port.Protocol]][maps.TC_DIR_IGR].natVal.
-							AddEp(podAddr, portLe, podMac, 0, 0, nil, true)
-					}
-					if s.isTargetPort(port, s.meshFilterPortOutbound) {
-						trustedAddrs[podAddrNb][portBe] = uint8(maps.ACL_AUDIT)
-						natPolicies[corev1Protos[port.Protocol]][maps.TC_DIR_EGR].natVal.
-							AddEp(podAddr, portLe, podMac, 0, 0, nil, true)
+			for _, c := range pod.Spec.Containers {
+				for _, port := range c.Ports {
+					if port.ContainerPort > 0 {
+						portLe := uint16(port.ContainerPort)
+						portBe := util.HostToNetShort(portLe)
+						if s.isTargetPort(port, s.meshFilterPortInbound) {
+							trustedAddrs[podAddrNb][portBe] = uint8(maps.ACL_AUDIT)
+							natPolicies[v6][corev1Protos[port.Protocol]][maps.TC_DIR_IGR].
+								addSidecarEp(pod, podAddr, portLe, podMac)
+						}
+						if s.isTargetPort(port, s.meshFilterPortOutbound) {
+							trustedAddrs[podAddrNb][portBe] = uint8(maps.ACL_AUDIT)
+							natPolicies[v6][corev1Protos[port.Protocol]][maps.TC_DIR_EGR].
+								addSidecarEp(pod, podAddr, portLe, podMac)
+						}
 					}
 				}
 			}
@@ -164,7 +159,7 @@ func (s *server) configMeshNatPolicies() {
 
 	for addrNb, ports := range trustedAddrs {
 		aclKey := new(maps.AclKey)
-		aclKey.Addr[0] = addrNb
+		aclKey.Addr = addrNb
 
 		aclVal := new(maps.AclVal)
 		aclVal.Flag = sidecarAclFlag
@@ -186,7 +181,7 @@ func (s *server) configMeshNatPolicies() {
 		if aclVal.Flag != sidecarAclFlag || aclVal.Id != sidecarAclId {
 			continue
 		}
-		if ports, trustedAddr := trustedAddrs[aclKey.Addr[0]]; trustedAddr {
+		if ports, trustedAddr := trustedAddrs[aclKey.Addr]; trustedAddr {
 			if _, trustedPort := ports[aclKey.Port]; trustedPort {
 				continue
 			}
@@ -196,25 +191,54 @@ func (s *server) configMeshNatPolicies() {
 		}
 	}
 
-	for _, proto := range supportedProtos {
-		for _, tcdir := range supportedTcdirs {
-			policy := natPolicies[proto][tcdir]
-			chash, _ := hashstructure.Hash(policy.natVal, hashstructure.FormatV2,
-				&hashstructure.HashOptions{
-					ZeroNil:         true,
-					IgnoreZeroValue: true,
-					SlicesAsSets:    true,
-				})
-			if policy.hash != chash {
-				policy.hash = chash
-				if err := maps.AddNatEntry(maps.SysMesh, policy.natKey, policy.natVal); err != nil {
-					log.Error().Err(err).Msg(policy.natKey.String())
+	for _, v6 := range supportedV6s {
+		for _, proto := range supportedProtos {
+			for _, tcdir := range supportedTcdirs {
+				policy := natPolicies[v6][proto][tcdir]
+				chash, _ := hashstructure.Hash(policy.natVal, hashstructure.FormatV2,
+					&hashstructure.HashOptions{
+						ZeroNil:         true,
+						IgnoreZeroValue: true,
+						SlicesAsSets:    true,
+					})
+				if policy.hash != chash {
+					policy.hash = chash
+					if err := maps.AddNatEntry(maps.SysMesh, policy.natKey, policy.natVal); err != nil {
+						log.Error().Err(err).Msg(policy.natKey.String())
+					}
 				}
 			}
 		}
 	}
 }
 
+// addSidecarEp adds the sidecar's ep, the ep of a terminating sidecar is drained until its deletion,
+// and is not added back once removed by the reaper.
+func (p *NatPolicy) addSidecarEp(pod *corev1.Pod, podAddr net.IP, port uint16, podMac []uint8) {
+	if pod.DeletionTimestamp == nil {
+		_, _ = p.natVal.AddEp(podAddr, port, podMac, 0, 0, nil, maps.NatEpDefaultWeight, true)
+		return
+	}
+	if p.existsVal == nil || !p.existsVal.HasEp(podAddr, port) {
+		return
+	}
+	if added, _ := p.natVal.AddEp(podAddr, port, podMac, 0, 0, nil, maps.NatEpDefaultWeight, false); added {
+		_, _ = p.natVal.DrainEp(podAddr, port, pod.DeletionTimestamp.Time)
+	}
+}
+
+// getPodAddrs returns all addrs of a pod, honouring dual-stack pod ips
+func getPodAddrs(pod *corev1.Pod) []net.IP {
+	var podAddrs []net.IP
+	for _, podIP := range pod.Status.PodIPs {
+		podAddrs = append(podAddrs, net.ParseIP(podIP.IP))
+	}
+	if len(podAddrs) == 0 && len(pod.Status.PodIP) > 0 {
+		podAddrs = append(podAddrs, net.ParseIP(pod.Status.PodIP))
+	}
+	return podAddrs
+}
+
 func (s *server) isTargetPort(port corev1.ContainerPort, flag string) bool {
 	return strings.Contains(strings.ToLower(port.Name), flag)
 }
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/flomesh-io/xnet/pkg/k8s/events"
+	"github.com/flomesh-io/xnet/pkg/k8s/kind"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+)
+
+// addrPurgeQueue collects the addrs of deleted pods, so that the cni DEL returns
+// without waiting for the flow maps to be walked.
+type addrPurgeQueue struct {
+	mu     sync.Mutex
+	addrs  map[string]net.IP
+	signal chan struct{}
+}
+
+func newAddrPurgeQueue() *addrPurgeQueue {
+	return &addrPurgeQueue{
+		addrs:  make(map[string]net.IP),
+		signal: make(chan struct{}, 1),
+	}
+}
+
+func (q *addrPurgeQueue) add(addrs ...net.IP) {
+	q.mu.Lock()
+	for _, addr := range addrs {
+		q.addrs[addr.String()] = addr
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (q *addrPurgeQueue) take() []net.IP {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	addrs := make([]net.IP, 0, len(q.addrs))
+	for _, addr := range q.addrs {
+		addrs = append(addrs, addr)
+	}
+	q.addrs = make(map[string]net.IP)
+	return addrs
+}
+
+// purgeDeletedPods purges the flows of deleted pods in batches, then resyncs
+// acl and nat policies, so that no entry points at the pods any more.
+func (s *server) purgeDeletedPods() {
+	sysIds := []maps.SysID{maps.SysMesh}
+	if s.enableE4lb {
+		sysIds = append(sysIds, maps.SysE4lb)
+	}
+
+	purgeTimer := time.NewTimer(podsPurgeSlidingWindow)
+	purgeTimer.Stop()
+	defer purgeTimer.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.podPurges.signal:
+			purgeTimer.Reset(podsPurgeSlidingWindow)
+		case <-purgeTimer.C:
+			podAddrs := s.podPurges.take()
+			if len(podAddrs) == 0 {
+				continue
+			}
+			purged, err := maps.PurgeAddrEntries(sysIds, podAddrs)
+			if err != nil {
+				log.Error().Err(err).Msgf("fail to purge flows of %v", podAddrs)
+				s.podPurges.add(podAddrs...)
+				purgeTimer.Reset(podsRepairRetryPeriod)
+				continue
+			}
+			log.Debug().Msgf("%d flows of %v purged", purged, podAddrs)
+
+			s.msgBroker.GetQueue().AddRateLimited(events.PubSubMessage{
+				Kind: kind.SidecarUpdate,
+			})
+		}
+	}
+}
@@ -7,28 +7,134 @@ import (
 	"strings"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/flomesh-io/xnet/pkg/constants"
+	"github.com/flomesh-io/xnet/pkg/k8s/events"
+	"github.com/flomesh-io/xnet/pkg/k8s/kind"
 	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
 	"github.com/flomesh-io/xnet/pkg/xnet/e4lb"
+	"github.com/flomesh-io/xnet/pkg/xnet/metrics"
 	"github.com/flomesh-io/xnet/pkg/xnet/ns"
 	"github.com/flomesh-io/xnet/pkg/xnet/tc"
 	"github.com/flomesh-io/xnet/pkg/xnet/volume"
 )
 
+// checkAndRepairPods attaches the mesh prog to monitored pods, driven by pod and
+// monitored namespace informer events and netns creations, with a slow full resync
+// as a safety net.
 func (s *server) checkAndRepairPods() {
+	kubeEventPubSub := s.msgBroker.GetKubeEventPubSub()
+	podEventChan := kubeEventPubSub.Sub(kind.PodAdded.String(), kind.PodUpdated.String(), kind.PodDeleted.String(),
+		kind.NamespaceAdded.String(), kind.NamespaceDeleted.String())
+	defer s.msgBroker.Unsub(kubeEventPubSub, podEventChan)
+
+	var netnsEventChan chan fsnotify.Event
+	var netnsErrorChan chan error
+	if netnsWatcher, err := watchNetnsDirs(); err != nil {
+		log.Error().Err(err).Msg("fail to watch netns dirs")
+	} else {
+		defer netnsWatcher.Close()
+		netnsEventChan = netnsWatcher.Events
+		netnsErrorChan = netnsWatcher.Errors
+	}
+
+	repairTimer := time.NewTimer(0)
+	defer repairTimer.Stop()
+	resyncTicker := time.NewTicker(podsRepairResyncPeriod)
+	defer resyncTicker.Stop()
+	recheck := false
+
 	for {
-		repairFailPods := s.doCheckAndRepairPods()
-		if len(repairFailPods) == 0 {
-			time.Sleep(time.Second * 10)
-			continue
+		select {
+		case <-s.stop:
+			return
+		case msg, ok := <-podEventChan:
+			if !ok {
+				return
+			}
+			if psm, isPsm := msg.(events.PubSubMessage); isPsm && isPodRepairRequired(psm) {
+				repairTimer.Reset(podsRepairSlidingWindow)
+			}
+		case event, ok := <-netnsEventChan:
+			if !ok {
+				netnsEventChan = nil
+				continue
+			}
+			if event.Op&fsnotify.Create != 0 {
+				log.Debug().Msgf("netns created: %s", event.Name)
+				repairTimer.Reset(podsRepairSlidingWindow)
+			}
+		case err, ok := <-netnsErrorChan:
+			if !ok {
+				netnsErrorChan = nil
+				continue
+			}
+			log.Error().Err(err).Msg("netns watcher")
+		case <-resyncTicker.C:
+			s.podNetns.invalidate()
+			recheck = true
+			repairTimer.Reset(0)
+		case <-repairTimer.C:
+			repairFailPods := s.doCheckAndRepairPods(recheck)
+			recheck = false
+			for _, pod := range repairFailPods {
+				log.Error().Msgf(`fail to check and repair pod: %s`, pod)
+			}
+			if len(repairFailPods) > 0 {
+				repairTimer.Reset(podsRepairRetryPeriod)
+			}
+		}
+	}
+}
+
+func watchNetnsDirs() (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, netnsDir := range volume.Netns {
+		if addErr := watcher.Add(netnsDir); addErr != nil {
+			log.Debug().Err(addErr).Msg(netnsDir)
 		}
-		for _, pod := range repairFailPods {
-			log.Error().Msgf(`fail to check and repair pod: %s`, pod)
+	}
+	return watcher, nil
+}
+
+// isPodRepairRequired returns whether a pod or namespace event may change the set of pods to be attached
+func isPodRepairRequired(msg events.PubSubMessage) bool {
+	switch msg.Kind {
+	case kind.NamespaceAdded, kind.NamespaceDeleted:
+		// the pods of a namespace labelled in or out of the mesh
+		return true
+	case kind.PodAdded:
+		if pod, ok := msg.NewObj.(*corev1.Pod); ok {
+			return len(pod.Status.PodIP) > 0
 		}
-		time.Sleep(time.Second * 3)
+	case kind.PodUpdated:
+		newPod, newOk := msg.NewObj.(*corev1.Pod)
+		oldPod, oldOk := msg.OldObj.(*corev1.Pod)
+		if !newOk || !oldOk {
+			return false
+		}
+		if len(newPod.Status.PodIP) == 0 {
+			return false
+		}
+		if newPod.Status.PodIP != oldPod.Status.PodIP {
+			return true
+		}
+		_, newSidecar := newPod.Labels[constants.SidecarUniqueIDLabelName]
+		_, oldSidecar := oldPod.Labels[constants.SidecarUniqueIDLabelName]
+		return newSidecar != oldSidecar
 	}
+	return false
 }
 
-func (s *server) doCheckAndRepairPods() map[string]string {
+// doCheckAndRepairPods attaches the monitored pods and detaches the others,
+// with recheck the tc filters of the pods cached as attached are verified too.
+func (s *server) doCheckAndRepairPods(recheck bool) map[string]string {
+	attachedPods := 0
 	allPodsByAddr := make(map[string]string)
 	monitoredPodsByAddr := make(map[string]string)
 	pods := s.kubeController.ListMonitoredPods()
@@ -43,58 +149,56 @@ func (s *server) doCheckAndRepairPods() map[string]string {
 	log.Debug().Msgf("monitoredPodsByAddr Count: %d", len(monitoredPodsByAddr))
 	log.Debug().Msgf("allPodsByAddr Count: %d", len(allPodsByAddr))
 
-	for _, netnsDir := range volume.Netns {
-		if len(monitoredPodsByAddr) == 0 {
-			break
-		}
-		rd, err := os.ReadDir(netnsDir)
-		if err != nil {
-			log.Debug().Err(err).Msg(netnsDir)
+	s.podNetns.refresh()
+
+	for addrStr, pod := range allPodsByAddr {
+		entry, found := s.podNetns.lookup(addrStr)
+		if !found || entry.Iface != podEth0 {
 			continue
 		}
-		for _, fi := range rd {
-			nsName, inode := ns.GetInode(fi, netnsDir)
-			netNS, nsErr := ns.GetNS(inode)
-			if nsErr != nil {
-				log.Debug().Err(nsErr).Msg(nsName)
-				continue
+		if _, exists := monitoredPodsByAddr[addrStr]; exists {
+			if entry.State == podAttachAttached && recheck {
+				attached := false
+				if checkErr := ns.WithNetNSPath(entry.Netns, func(_ ns.NetNS) error {
+					var err error
+					attached, err = tc.HasBPFProg(entry.Iface, true, true)
+					return err
+				}); checkErr != nil {
+					log.Debug().Err(checkErr).Msg(pod)
+				}
+				if !attached {
+					log.Warn().Msgf("monitoredPodsByAddr:%s lost its tc filters", addrStr)
+					entry.State = podAttachUnknown
+				}
 			}
-
-			if doErr := netNS.Do(func(_ ns.NetNS) error {
-				if iface, ifaceErr := net.InterfaceByName(podEth0); ifaceErr == nil {
-					if (iface.Flags&net.FlagLoopback) == 0 && (iface.Flags&net.FlagUp) != 0 {
-						if addrs, addrErr := iface.Addrs(); addrErr == nil {
-							for _, addr := range addrs {
-								addrStr := addr.String()
-								addrStr = addrStr[0:strings.Index(addrStr, `/`)]
-								log.Debug().Msgf("netns Addr:%s %s", iface.Name, addrStr)
-								if pod, exists := monitoredPodsByAddr[addrStr]; exists {
-									log.Debug().Msgf("monitoredPodsByAddr:%s", addrStr)
-									if attachErr := tc.AttachBPFProg(maps.SysMesh, iface.Name, true, true); attachErr != nil {
-										return fmt.Errorf(`%s %s`, pod, attachErr.Error())
-									}
-									log.Debug().Msgf("monitoredPodsByAddr:%s attach success", addrStr)
-									delete(monitoredPodsByAddr, addrStr)
-									delete(allPodsByAddr, addrStr)
-								} else if pod, exists := allPodsByAddr[addrStr]; exists {
-									log.Debug().Msgf("allPodsByAddr:%s", addrStr)
-									if detachErr := tc.DetachBPFProg(maps.SysMesh, iface.Name, true, true); detachErr != nil {
-										return fmt.Errorf(`%s %s`, pod, detachErr.Error())
-									}
-									log.Debug().Msgf("allPodsByAddr:%s detach success", addrStr)
-									delete(allPodsByAddr, addrStr)
-								}
-							}
-						}
-					}
+			if entry.State != podAttachAttached {
+				log.Debug().Msgf("monitoredPodsByAddr:%s", addrStr)
+				if attachErr := ns.WithNetNSPath(entry.Netns, func(_ ns.NetNS) error {
+					return tc.AttachBPFProg(maps.SysMesh, entry.Iface, true, true)
+				}); attachErr != nil {
+					log.Debug().Err(attachErr).Msg(pod)
+					continue
 				}
-				return nil
-			}); doErr != nil {
-				log.Debug().Err(doErr).Msg(nsName)
+				log.Debug().Msgf("monitoredPodsByAddr:%s attach success", addrStr)
+				s.podNetns.setState(addrStr, pod, podAttachAttached)
 			}
+			attachedPods++
+			delete(monitoredPodsByAddr, addrStr)
+		} else if entry.State != podAttachDetached {
+			log.Debug().Msgf("allPodsByAddr:%s", addrStr)
+			if detachErr := ns.WithNetNSPath(entry.Netns, func(_ ns.NetNS) error {
+				return tc.DetachBPFProg(maps.SysMesh, entry.Iface, true, true)
+			}); detachErr != nil {
+				log.Debug().Err(detachErr).Msg(pod)
+				continue
+			}
+			log.Debug().Msgf("allPodsByAddr:%s detach success", addrStr)
+			s.podNetns.setState(addrStr, pod, podAttachDetached)
 		}
 	}
 	log.Debug().Msgf("monitoredPodsByAddr Attach Fail Count: %d", len(monitoredPodsByAddr))
+	metrics.PodsAttached.Set(float64(attachedPods))
+	metrics.PodsAttachFailed.Set(float64(len(monitoredPodsByAddr)))
 	return monitoredPodsByAddr
 }
 
@@ -118,6 +222,35 @@ func (s *server) doCheckAndResetPods() map[string]string {
 	}
 	log.Debug().Msgf("allPodsByAddr Count: %d", len(allPodsByAddr))
 
+	s.podNetns.refresh()
+
+	for addrStr, pod := range allPodsByAddr {
+		entry, found := s.podNetns.lookup(addrStr)
+		if !found || entry.Iface != podEth0 {
+			continue
+		}
+		if entry.State != podAttachDetached {
+			if detachErr := ns.WithNetNSPath(entry.Netns, func(_ ns.NetNS) error {
+				return tc.DetachBPFProg(maps.SysMesh, entry.Iface, true, true)
+			}); detachErr != nil {
+				log.Debug().Err(detachErr).Msg(pod)
+				continue
+			}
+			log.Debug().Msgf("allPodsByAddr:%s detach success", addrStr)
+			s.podNetns.setState(addrStr, pod, podAttachDetached)
+		}
+		delete(allPodsByAddr, addrStr)
+	}
+	return allPodsByAddr
+}
+
+func (s *server) checkAndUpgradePods() {
+	allPodsByAddr := make(map[string]string)
+	pods := s.kubeController.ListAllPods()
+	for _, pod := range pods {
+		allPodsByAddr[pod.Status.PodIP] = fmt.Sprintf(`%s/%s`, pod.Namespace, pod.Name)
+	}
+
 	for _, netnsDir := range volume.Netns {
 		if len(allPodsByAddr) == 0 {
 			break
@@ -142,12 +275,11 @@ func (s *server) doCheckAndResetPods() map[string]string {
 							for _, addr := range addrs {
 								addrStr := addr.String()
 								addrStr = addrStr[0:strings.Index(addrStr, `/`)]
-								log.Debug().Msgf("netns Addr:%s %s", iface.Name, addrStr)
 								if pod, exists := allPodsByAddr[addrStr]; exists {
-									if detachErr := tc.DetachBPFProg(maps.SysMesh, iface.Name, true, true); detachErr != nil {
-										return fmt.Errorf(`%s %s`, pod, detachErr.Error())
+									if replaceErr := tc.ReplaceBPFProg(maps.SysMesh, iface.Name, true, true); replaceErr != nil {
+										return fmt.Errorf(`%s %s`, pod, replaceErr.Error())
 									}
-									log.Debug().Msgf("allPodsByAddr:%s detach success", addrStr)
+									log.Debug().Msgf("allPodsByAddr:%s upgrade success", addrStr)
 									delete(allPodsByAddr, addrStr)
 								}
 							}
@@ -156,11 +288,10 @@ func (s *server) doCheckAndResetPods() map[string]string {
 				}
 				return nil
 			}); nsErr != nil {
-				log.Debug().Err(nsErr).Msg(nsName)
+				log.Error().Err(nsErr).Msg(nsName)
 			}
 		}
 	}
-	return allPodsByAddr
 }
 
 func (s *server) checkAndRepairE4lb() {
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"k8s.io/client-go/kubernetes"
 
 	"github.com/flomesh-io/xnet/pkg/k8s"
 	"github.com/flomesh-io/xnet/pkg/messaging"
@@ -19,18 +20,24 @@ import (
 	"github.com/flomesh-io/xnet/pkg/xnet/cni"
 	"github.com/flomesh-io/xnet/pkg/xnet/cni/deliver"
 	"github.com/flomesh-io/xnet/pkg/xnet/e4lb"
+	"github.com/flomesh-io/xnet/pkg/xnet/health"
+	"github.com/flomesh-io/xnet/pkg/xnet/neigh"
 	"github.com/flomesh-io/xnet/pkg/xnet/volume"
 )
 
 type server struct {
 	ctx            context.Context
+	kubeClient     kubernetes.Interface
 	kubeController k8s.Controller
 	msgBroker      *messaging.Broker
+	fsmNamespace   string
 	stop           chan struct{}
 
 	enableE4lb     bool
 	enableE4lbIPv4 bool
 	enableE4lbIPv6 bool
+	e4lbVipPools   []*e4lb.VipPool
+	e4lbLbClass    string
 
 	enableMesh bool
 
@@ -57,21 +64,33 @@ type server struct {
 	flushUDPConnTrackBatchSize   int
 
 	cniBridges []net.Interface
+
+	podNetns  *podNetnsCache
+	podPurges *addrPurgeQueue
+
+	e4lbLeader     *e4lb.Leader
+	e4lbNatHashes  map[maps.NatKey]uint64
+	e4lbAnnouncers map[string]context.CancelFunc
+	epResolver     *neigh.Resolver
+	epHealth       *health.Checker
 }
 
 // NewServer returns a new CNI Server.
 // the path this the unix path to listen.
-func NewServer(ctx context.Context,
-	kubeController k8s.Controller, msgBroker *messaging.Broker, stop chan struct{},
+func NewServer(ctx context.Context, kubeClient kubernetes.Interface,
+	kubeController k8s.Controller, msgBroker *messaging.Broker, fsmNamespace string, stop chan struct{},
 	enableE4lb, enableE4lbIPv4, enableE4lbIPv6, enableMesh, upgradeProg, uninstallProg bool, cniBridges []net.Interface,
+	e4lbVipPools []*e4lb.VipPool, e4lbLbClass string, e4lbHealthCheck health.Config,
 	meshCfgIPv4Magic, meshCfgIPv6Magic, e4lbCfgIPv4Magic, e4lbCfgIPv6Magic string,
 	meshFilterPortInbound, meshFilterPortOutbound string,
 	flushTCPConnTrackCrontab string, flushTCPConnTrackIdleSeconds, flushTCPConnTrackBatchSize int,
 	flushUDPConnTrackCrontab string, flushUDPConnTrackIdleSeconds, flushUDPConnTrackBatchSize int) Server {
-	return &server{
+	s := &server{
 		unixSockPath:   cni.GetCniSock(volume.SysRun.MountPath),
+		kubeClient:     kubeClient,
 		kubeController: kubeController,
 		msgBroker:      msgBroker,
+		fsmNamespace:   fsmNamespace,
 		cniReady:       make(chan struct{}, 1),
 		ctx:            ctx,
 		stop:           stop,
@@ -79,6 +98,8 @@ func NewServer(ctx context.Context,
 		enableE4lb:     enableE4lb,
 		enableE4lbIPv4: enableE4lbIPv4,
 		enableE4lbIPv6: enableE4lbIPv6,
+		e4lbVipPools:   e4lbVipPools,
+		e4lbLbClass:    e4lbLbClass,
 
 		enableMesh: enableMesh,
 
@@ -102,24 +123,54 @@ func NewServer(ctx context.Context,
 		flushUDPConnTrackBatchSize:   flushUDPConnTrackBatchSize,
 
 		cniBridges: cniBridges,
+
+		podNetns:  newPodNetnsCache(),
+		podPurges: newAddrPurgeQueue(),
+
+		e4lbLeader:     e4lb.NewLeader(kubeClient, fsmNamespace, os.Getenv("NODE_NAME")),
+		e4lbNatHashes:  make(map[maps.NatKey]uint64),
+		e4lbAnnouncers: make(map[string]context.CancelFunc),
+		epResolver:     neigh.NewResolver(),
+	}
+	if e4lbHealthCheck.Enabled() {
+		s.epHealth = health.NewChecker(e4lbHealthCheck)
 	}
+	return s
 }
 
 func (s *server) Start() error {
-	if s.upgradeProg || s.uninstallProg {
+	if s.uninstallProg {
 		e4lb.E4lbOff()
 		s.uninstallCNI()
 		s.checkAndResetPods()
 		load.ProgUnload()
+	} else if s.upgradeProg {
+		if err := load.ProgUpgrade(); err != nil {
+			log.Fatal().Err(err).Msg("fail to upgrade bpf prog")
+		}
+		e4lb.E4lbUpgrade()
+		s.checkAndUpgradePods()
 	}
 
 	r := mux.NewRouter()
 	r.Path(cni.VersionURI).
 		Methods("GET").
 		HandlerFunc(version.VersionHandler)
+	r.Path(cni.PodNetnsURI).
+		Methods("GET").
+		HandlerFunc(s.PodNetnsList)
+	r.Path(cni.FlushTCPFlowsURI).
+		Methods("POST").
+		HandlerFunc(s.TCPFlowsFlush)
 
 	if !s.uninstallProg {
-		load.ProgLoad()
+		if upgraded, err := load.ProgLoad(); err != nil {
+			log.Fatal().Err(err).Msg("fail to load bpf prog")
+		} else if upgraded {
+			// the tc filters are swapped as with --upgrade-prog, or keep running the old maps
+			e4lb.E4lbUpgrade()
+			s.checkAndUpgradePods()
+		}
 		s.loadBridges()
 
 		if !s.enableE4lb {
@@ -127,6 +178,12 @@ func (s *server) Start() error {
 		} else {
 			load.InitE4lbConfig(s.enableE4lbIPv4, s.enableE4lbIPv6, s.e4lbCfgIPv4Magic, s.e4lbCfgIPv6Magic)
 			s.checkAndRepairE4lb()
+
+			go s.e4lbListener()
+
+			if s.epHealth != nil {
+				go s.epHealth.Run(s.stop)
+			}
 		}
 
 		if !s.enableMesh {
@@ -152,6 +209,8 @@ func (s *server) Start() error {
 
 			go s.checkAndRepairPods()
 
+			go s.purgeDeletedPods()
+
 			if len(s.flushTCPConnTrackCrontab) > 0 && s.flushTCPConnTrackIdleSeconds > 0 && s.flushTCPConnTrackBatchSize > 0 {
 				go s.idleTCPConnTrackFlush(maps.SysMesh)
 			}
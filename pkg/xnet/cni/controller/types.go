@@ -1,6 +1,10 @@
 package controller
 
-import "github.com/flomesh-io/xnet/pkg/logger"
+import (
+	"time"
+
+	"github.com/flomesh-io/xnet/pkg/logger"
+)
 
 var (
 	log = logger.New("fsm-xnet-ctrl")
@@ -14,6 +18,15 @@ const (
 	bridgeAclFlag = uint8('c')
 
 	podEth0 = `eth0`
+
+	// podsRepairSlidingWindow is the sliding window used to batch pod and netns events
+	podsRepairSlidingWindow = time.Second
+	// podsRepairRetryPeriod is the delay before retrying pods which failed to be attached
+	podsRepairRetryPeriod = time.Second * 3
+	// podsRepairResyncPeriod is the period of the full netns scan, as a safety net for missed events
+	podsRepairResyncPeriod = time.Minute * 5
+	// podsPurgeSlidingWindow is the sliding window used to batch the flow purges of deleted pods
+	podsPurgeSlidingWindow = time.Second
 )
 
 // Server CNI Server.
@@ -13,6 +13,11 @@ const (
 	CreatePodURI = "/v1/cni/create-pod"
 	// DeletePodURI is the route for cni plugin for deleting pod
 	DeletePodURI = "/v1/cni/delete-pod"
+	// PodNetnsURI is the debug route for listing the indexed pods' netns
+	PodNetnsURI = "/v1/debug/pods"
+	// FlushTCPFlowsURI is the route for the cli flushing idle tcp flows,
+	// the conns of deleted flows are released by xctr only
+	FlushTCPFlowsURI = "/v1/flows/tcp/flush"
 
 	VersionURI = "/version"
 )
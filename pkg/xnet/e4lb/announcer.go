@@ -0,0 +1,169 @@
+package e4lb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/arp"
+	"github.com/flomesh-io/xnet/pkg/xnet/util/route"
+)
+
+const (
+	vipLeaseDuration = time.Second * 8
+	vipRenewDeadline = time.Second * 5
+	vipRetryPeriod   = time.Second * 2
+
+	// vipAnnounceBurst gratuitous arps or unsolicited neighbor advertisements are sent every vipAnnounceBurstInterval on takeover,
+	// then every vipAnnounceInterval as long as the leadership is held.
+	vipAnnounceBurst         = 3
+	vipAnnounceBurstInterval = time.Millisecond * 500
+	vipAnnounceInterval      = time.Second * 30
+)
+
+// VipAnnouncer announces a vip from the node holding its lease
+type VipAnnouncer struct {
+	vip        net.IP
+	kubeClient kubernetes.Interface
+	namespace  string
+	identity   string
+}
+
+// NewVipAnnouncer creates a vip announcer, competing for the vip's lease in the namespace as identity
+func NewVipAnnouncer(vip net.IP, kubeClient kubernetes.Interface, namespace, identity string) *VipAnnouncer {
+	return &VipAnnouncer{
+		vip:        vip,
+		kubeClient: kubeClient,
+		namespace:  namespace,
+		identity:   identity,
+	}
+}
+
+// Run competes for the vip's lease until ctx is done,
+// the vip is announced while the lease is held and withdrawn when it is lost.
+func (a *VipAnnouncer) Run(ctx context.Context) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      getVipLeaseName(a.vip),
+			Namespace: a.namespace,
+		},
+		Client: a.kubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: a.identity,
+		},
+	}
+
+	for {
+		elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			LeaseDuration:   vipLeaseDuration,
+			RenewDeadline:   vipRenewDeadline,
+			RetryPeriod:     vipRetryPeriod,
+			ReleaseOnCancel: true,
+			Name:            lock.LeaseMeta.Name,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: a.announce,
+				OnStoppedLeading: a.withdraw,
+				OnNewLeader: func(identity string) {
+					log.Info().Msgf("vip %s is announced by %s", a.vip, identity)
+				},
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msgf("fail to elect announcer of vip %s", a.vip)
+			return
+		}
+		elector.Run(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(vipRetryPeriod):
+		}
+	}
+}
+
+func (a *VipAnnouncer) announce(ctx context.Context) {
+	dev, iface, err := getAnnounceDevice()
+	if err != nil {
+		log.Error().Err(err).Msgf("fail to announce vip %s", a.vip)
+		return
+	}
+	log.Info().Msgf("vip %s taken over, announcing on %s", a.vip, dev)
+
+	// route locally originated traffic to the vip through the e4lb device
+	neigh := &netlink.Neigh{
+		LinkIndex:    iface.Index,
+		State:        arp.NUD_REACHABLE,
+		IP:           a.vip,
+		HardwareAddr: iface.HardwareAddr,
+	}
+	if err = netlink.NeighSet(neigh); err != nil {
+		log.Error().Msg(err.Error())
+		if err = netlink.NeighAdd(neigh); err != nil {
+			log.Error().Msg(err.Error())
+		}
+	}
+
+	announces := 0
+	announceTimer := time.NewTimer(0)
+	defer announceTimer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-announceTimer.C:
+			if err = arp.Announce(dev, a.vip.String(), iface.HardwareAddr); err != nil {
+				log.Error().Err(err).Msgf("fail to announce vip %s", a.vip)
+			}
+			announces++
+			if announces < vipAnnounceBurst {
+				announceTimer.Reset(vipAnnounceBurstInterval)
+			} else {
+				announceTimer.Reset(vipAnnounceInterval)
+			}
+		}
+	}
+}
+
+func (a *VipAnnouncer) withdraw() {
+	_, iface, err := getAnnounceDevice()
+	if err != nil {
+		log.Error().Err(err).Msgf("fail to withdraw vip %s", a.vip)
+		return
+	}
+	log.Info().Msgf("vip %s lost, withdrawn", a.vip)
+
+	neigh := &netlink.Neigh{
+		LinkIndex: iface.Index,
+		IP:        a.vip,
+	}
+	if err = netlink.NeighDel(neigh); err != nil {
+		log.Debug().Err(err).Msgf("fail to delete neigh of vip %s", a.vip)
+	}
+}
+
+func getAnnounceDevice() (string, *net.Interface, error) {
+	dev, _, err := route.DiscoverGateway()
+	if err != nil {
+		return "", nil, err
+	}
+	iface, err := net.InterfaceByName(dev)
+	if err != nil {
+		return "", nil, err
+	}
+	return dev, iface, nil
+}
+
+func getVipLeaseName(vip net.IP) string {
+	name := strings.NewReplacer(`.`, `-`, `:`, `-`).Replace(vip.String())
+	return fmt.Sprintf("fsm-xnet-vip-%s", strings.Trim(name, `-`))
+}
@@ -40,3 +40,19 @@ func E4lbOff() {
 		}
 	}
 }
+
+func E4lbUpgrade() {
+	dev, _, err := route.DiscoverGateway()
+	if err != nil {
+		log.Error().Err(err).Msg("fail to find default net device.")
+		return
+	}
+
+	if iface, ifaceErr := net.InterfaceByName(dev); ifaceErr != nil {
+		log.Error().Err(ifaceErr).Msgf("fail to find %s link", dev)
+	} else {
+		if replaceErr := tc.ReplaceBPFProg(maps.SysE4lb, dev, true, true); replaceErr != nil {
+			log.Error().Err(replaceErr).Msgf("fail to upgrade %s link: %d", dev, iface.Index)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package e4lb
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// VipPool is a named set of vip ranges
+type VipPool struct {
+	Name   string
+	ranges []vipRange
+}
+
+type vipRange struct {
+	first netip.Addr
+	last  netip.Addr
+}
+
+// ParseVipPool parses a pool spec: <name>=<cidr|first-last>[,<cidr|first-last>...]
+func ParseVipPool(spec string) (*VipPool, error) {
+	name, rangeSpecs, found := strings.Cut(spec, `=`)
+	name = strings.TrimSpace(name)
+	if !found || len(name) == 0 || len(rangeSpecs) == 0 {
+		return nil, fmt.Errorf("invalid vip pool: %s", spec)
+	}
+
+	pool := &VipPool{Name: name}
+	for _, rangeSpec := range strings.Split(rangeSpecs, `,`) {
+		r, err := parseVipRange(strings.TrimSpace(rangeSpec))
+		if err != nil {
+			return nil, fmt.Errorf("invalid vip pool %s: %w", name, err)
+		}
+		pool.ranges = append(pool.ranges, r)
+	}
+	return pool, nil
+}
+
+func parseVipRange(spec string) (vipRange, error) {
+	if strings.Contains(spec, `/`) {
+		prefix, err := netip.ParsePrefix(spec)
+		if err != nil {
+			return vipRange{}, err
+		}
+		prefix = prefix.Masked()
+		first := prefix.Addr()
+		last := lastAddr(prefix)
+		// network and broadcast addrs are not usable
+		if first.Is4() && prefix.Bits() < 31 {
+			first = first.Next()
+			last = last.Prev()
+		}
+		return vipRange{first: first, last: last}, nil
+	}
+
+	firstSpec, lastSpec, found := strings.Cut(spec, `-`)
+	first, err := netip.ParseAddr(strings.TrimSpace(firstSpec))
+	if err != nil {
+		return vipRange{}, err
+	}
+	last := first
+	if found {
+		if last, err = netip.ParseAddr(strings.TrimSpace(lastSpec)); err != nil {
+			return vipRange{}, err
+		}
+	}
+	if first.Is4() != last.Is4() || last.Less(first) {
+		return vipRange{}, fmt.Errorf("invalid vip range: %s", spec)
+	}
+	return vipRange{first: first, last: last}, nil
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bs := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(bs)*8; bit++ {
+		bs[bit/8] |= 1 << (7 - bit%8)
+	}
+	addr, _ := netip.AddrFromSlice(bs)
+	return addr
+}
+
+// Contains returns whether the vip belongs to the pool
+func (p *VipPool) Contains(vip netip.Addr) bool {
+	vip = vip.Unmap()
+	for _, r := range p.ranges {
+		if r.first.Is4() == vip.Is4() && !vip.Less(r.first) && !r.last.Less(vip) {
+			return true
+		}
+	}
+	return false
+}
+
+// VipAllocator allocates vips of pools to services
+type VipAllocator struct {
+	pools     []*VipPool
+	allocated map[netip.Addr]string
+}
+
+// NewVipAllocator creates a vip allocator, the first pool is the default one
+func NewVipAllocator(pools []*VipPool) *VipAllocator {
+	return &VipAllocator{
+		pools:     pools,
+		allocated: make(map[netip.Addr]string),
+	}
+}
+
+// Pool returns the pool with the name, or the default pool if name is empty
+func (a *VipAllocator) Pool(name string) *VipPool {
+	if len(a.pools) == 0 {
+		return nil
+	}
+	if len(name) == 0 {
+		return a.pools[0]
+	}
+	for _, pool := range a.pools {
+		if pool.Name == name {
+			return pool
+		}
+	}
+	return nil
+}
+
+// PoolOf returns the pool containing the vip
+func (a *VipAllocator) PoolOf(vip netip.Addr) *VipPool {
+	for _, pool := range a.pools {
+		if pool.Contains(vip) {
+			return pool
+		}
+	}
+	return nil
+}
+
+// Owner returns the service owning the vip
+func (a *VipAllocator) Owner(vip netip.Addr) (string, bool) {
+	owner, exists := a.allocated[vip.Unmap()]
+	return owner, exists
+}
+
+// Assign marks the vip as owned by the service,
+// returns an error if it is owned by another service.
+func (a *VipAllocator) Assign(svc string, vip netip.Addr) error {
+	vip = vip.Unmap()
+	if owner, exists := a.allocated[vip]; exists && owner != svc {
+		return fmt.Errorf("vip %s is owned by %s", vip, owner)
+	}
+	a.allocated[vip] = svc
+	return nil
+}
+
+// Allocate assigns the lowest free vip of the pool and family to the service
+func (a *VipAllocator) Allocate(svc string, pool *VipPool, v6 bool) (netip.Addr, error) {
+	for _, r := range pool.ranges {
+		if r.first.Is4() == v6 {
+			continue
+		}
+		for vip := r.first; vip.IsValid() && !r.last.Less(vip); vip = vip.Next() {
+			if _, exists := a.allocated[vip]; !exists {
+				a.allocated[vip] = svc
+				return vip, nil
+			}
+		}
+	}
+	return netip.Addr{}, fmt.Errorf("vip pool %s exhausted", pool.Name)
+}
+
+// Release frees all vips owned by the service
+func (a *VipAllocator) Release(svc string) {
+	for vip, owner := range a.allocated {
+		if owner == svc {
+			delete(a.allocated, vip)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package e4lb
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestParseVipPool(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     string
+		wantErr  bool
+		wantName string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "single addr",
+			spec:     "default=192.168.1.100",
+			wantName: "default",
+			contains: []string{"192.168.1.100"},
+			excludes: []string{"192.168.1.99", "192.168.1.101"},
+		},
+		{
+			name:     "addr range",
+			spec:     "default=192.168.1.100-192.168.1.200",
+			wantName: "default",
+			contains: []string{"192.168.1.100", "192.168.1.150", "192.168.1.200"},
+			excludes: []string{"192.168.1.99", "192.168.1.201"},
+		},
+		{
+			name:     "cidr skips network and broadcast addrs",
+			spec:     "pool=10.0.0.0/30",
+			wantName: "pool",
+			contains: []string{"10.0.0.1", "10.0.0.2"},
+			excludes: []string{"10.0.0.0", "10.0.0.3"},
+		},
+		{
+			name:     "unmasked cidr",
+			spec:     "pool=10.0.0.5/30",
+			wantName: "pool",
+			contains: []string{"10.0.0.5", "10.0.0.6"},
+			excludes: []string{"10.0.0.4", "10.0.0.7"},
+		},
+		{
+			name:     "point to point cidr",
+			spec:     "pool=10.0.0.0/31",
+			wantName: "pool",
+			contains: []string{"10.0.0.0", "10.0.0.1"},
+		},
+		{
+			name:     "ipv6 cidr keeps all addrs",
+			spec:     "v6=fd00::/126",
+			wantName: "v6",
+			contains: []string{"fd00::", "fd00::3"},
+			excludes: []string{"fd00::4"},
+		},
+		{
+			name:     "multiple ranges with spaces",
+			spec:     " mixed = 10.0.0.0/30 , fd00::1-fd00::2 ",
+			wantName: "mixed",
+			contains: []string{"10.0.0.1", "fd00::1", "fd00::2", "::ffff:10.0.0.2"},
+			excludes: []string{"fd00::3", "10.0.0.3"},
+		},
+		{
+			name:    "missing name",
+			spec:    "=10.0.0.0/30",
+			wantErr: true,
+		},
+		{
+			name:    "missing ranges",
+			spec:    "default=",
+			wantErr: true,
+		},
+		{
+			name:    "missing separator",
+			spec:    "10.0.0.0/30",
+			wantErr: true,
+		},
+		{
+			name:    "reversed range",
+			spec:    "default=10.0.0.9-10.0.0.1",
+			wantErr: true,
+		},
+		{
+			name:    "mixed families range",
+			spec:    "default=10.0.0.1-fd00::1",
+			wantErr: true,
+		},
+		{
+			name:    "invalid addr",
+			spec:    "default=10.0.0.256",
+			wantErr: true,
+		},
+		{
+			name:    "invalid cidr",
+			spec:    "default=10.0.0.0/33",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool, err := ParseVipPool(tc.spec)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ParseVipPool(%q) = %v, want error", tc.spec, pool.ranges)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseVipPool(%q) error: %v", tc.spec, err)
+			}
+			if pool.Name != tc.wantName {
+				t.Errorf("pool name = %q, want %q", pool.Name, tc.wantName)
+			}
+			for _, addr := range tc.contains {
+				if !pool.Contains(netip.MustParseAddr(addr)) {
+					t.Errorf("pool %s does not contain %s", tc.spec, addr)
+				}
+			}
+			for _, addr := range tc.excludes {
+				if pool.Contains(netip.MustParseAddr(addr)) {
+					t.Errorf("pool %s contains %s", tc.spec, addr)
+				}
+			}
+		})
+	}
+}
+
+func TestVipAllocatorAllocate(t *testing.T) {
+	type allocation struct {
+		svc     string
+		v6      bool
+		want    string
+		wantErr bool
+	}
+	testCases := []struct {
+		name     string
+		spec     string
+		assigned map[string]string
+		allocs   []allocation
+	}{
+		{
+			name: "lowest free addr first",
+			spec: "default=10.0.0.10-10.0.0.12",
+			allocs: []allocation{
+				{svc: "ns/a", want: "10.0.0.10"},
+				{svc: "ns/b", want: "10.0.0.11"},
+				{svc: "ns/c", want: "10.0.0.12"},
+			},
+		},
+		{
+			name: "exhausted pool",
+			spec: "default=10.0.0.10",
+			allocs: []allocation{
+				{svc: "ns/a", want: "10.0.0.10"},
+				{svc: "ns/b", wantErr: true},
+			},
+		},
+		{
+			name:     "skips assigned addrs",
+			spec:     "default=10.0.0.10-10.0.0.12",
+			assigned: map[string]string{"10.0.0.10": "ns/a", "10.0.0.11": "ns/b"},
+			allocs: []allocation{
+				{svc: "ns/c", want: "10.0.0.12"},
+			},
+		},
+		{
+			name: "continues in the next range",
+			spec: "default=10.0.0.10,10.0.1.0/30",
+			allocs: []allocation{
+				{svc: "ns/a", want: "10.0.0.10"},
+				{svc: "ns/b", want: "10.0.1.1"},
+				{svc: "ns/c", want: "10.0.1.2"},
+				{svc: "ns/d", wantErr: true},
+			},
+		},
+		{
+			name: "families are allocated separately",
+			spec: "dual=10.0.0.10,fd00::10-fd00::11",
+			allocs: []allocation{
+				{svc: "ns/a", v6: true, want: "fd00::10"},
+				{svc: "ns/a", want: "10.0.0.10"},
+				{svc: "ns/b", v6: true, want: "fd00::11"},
+				{svc: "ns/b", wantErr: true},
+			},
+		},
+		{
+			name: "no range of the family",
+			spec: "v4=10.0.0.10",
+			allocs: []allocation{
+				{svc: "ns/a", v6: true, wantErr: true},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool, err := ParseVipPool(tc.spec)
+			if err != nil {
+				t.Fatalf("ParseVipPool(%q) error: %v", tc.spec, err)
+			}
+			allocator := NewVipAllocator([]*VipPool{pool})
+			for addr, svc := range tc.assigned {
+				if err = allocator.Assign(svc, netip.MustParseAddr(addr)); err != nil {
+					t.Fatalf("Assign(%s, %s) error: %v", svc, addr, err)
+				}
+			}
+			for _, alloc := range tc.allocs {
+				vip, allocErr := allocator.Allocate(alloc.svc, pool, alloc.v6)
+				if alloc.wantErr {
+					if allocErr == nil {
+						t.Fatalf("Allocate(%s, v6=%t) = %s, want error", alloc.svc, alloc.v6, vip)
+					}
+					continue
+				}
+				if allocErr != nil {
+					t.Fatalf("Allocate(%s, v6=%t) error: %v", alloc.svc, alloc.v6, allocErr)
+				}
+				if vip != netip.MustParseAddr(alloc.want) {
+					t.Fatalf("Allocate(%s, v6=%t) = %s, want %s", alloc.svc, alloc.v6, vip, alloc.want)
+				}
+				if owner, _ := allocator.Owner(vip); owner != alloc.svc {
+					t.Fatalf("owner of %s = %q, want %q", vip, owner, alloc.svc)
+				}
+			}
+		})
+	}
+}
+
+func TestVipAllocatorAssignAndRelease(t *testing.T) {
+	pool, err := ParseVipPool("default=10.0.0.10-10.0.0.11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	allocator := NewVipAllocator([]*VipPool{pool})
+	vip := netip.MustParseAddr("10.0.0.10")
+
+	if err = allocator.Assign("ns/a", vip); err != nil {
+		t.Fatalf("Assign error: %v", err)
+	}
+	if err = allocator.Assign("ns/a", netip.MustParseAddr("::ffff:10.0.0.10")); err != nil {
+		t.Fatalf("Assign of the mapped addr by the owner error: %v", err)
+	}
+	if err = allocator.Assign("ns/b", vip); err == nil {
+		t.Fatal("Assign of an owned vip to another service succeeded")
+	}
+
+	allocator.Release("ns/a")
+	if _, exists := allocator.Owner(vip); exists {
+		t.Fatalf("vip %s still owned after release", vip)
+	}
+	if got, allocErr := allocator.Allocate("ns/b", pool, false); allocErr != nil || got != vip {
+		t.Fatalf("Allocate after release = %s, %v, want %s", got, allocErr, vip)
+	}
+}
@@ -0,0 +1,106 @@
+package e4lb
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	leaderLeaseName     = "fsm-xnet-e4lb"
+	leaderLeaseDuration = time.Second * 15
+	leaderRenewDeadline = time.Second * 10
+	leaderRetryPeriod   = time.Second * 2
+)
+
+// Leader elects the node running the cluster wide e4lb duties, e.g. writing the vips to service status
+type Leader struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	identity   string
+
+	leading atomic.Bool
+	changed chan struct{}
+}
+
+// NewLeader creates a leader elector, competing for the e4lb lease in the namespace as identity
+func NewLeader(kubeClient kubernetes.Interface, namespace, identity string) *Leader {
+	return &Leader{
+		kubeClient: kubeClient,
+		namespace:  namespace,
+		identity:   identity,
+		changed:    make(chan struct{}, 1),
+	}
+}
+
+// IsLeader returns whether the lease is held by this node
+func (l *Leader) IsLeader() bool {
+	return l.leading.Load()
+}
+
+// Changed is notified when the leadership is taken or lost
+func (l *Leader) Changed() <-chan struct{} {
+	return l.changed
+}
+
+// Run competes for the lease until ctx is done, the lease is released on return.
+func (l *Leader) Run(ctx context.Context) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      leaderLeaseName,
+			Namespace: l.namespace,
+		},
+		Client: l.kubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: l.identity,
+		},
+	}
+
+	for {
+		elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			LeaseDuration:   leaderLeaseDuration,
+			RenewDeadline:   leaderRenewDeadline,
+			RetryPeriod:     leaderRetryPeriod,
+			ReleaseOnCancel: true,
+			Name:            leaderLeaseName,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(context.Context) {
+					log.Info().Msgf("e4lb leadership taken by %s", l.identity)
+					l.setLeading(true)
+				},
+				OnStoppedLeading: func() {
+					log.Info().Msgf("e4lb leadership lost by %s", l.identity)
+					l.setLeading(false)
+				},
+				OnNewLeader: func(identity string) {
+					log.Info().Msgf("e4lb leader is %s", identity)
+				},
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("fail to elect e4lb leader")
+			return
+		}
+		elector.Run(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(leaderRetryPeriod):
+		}
+	}
+}
+
+func (l *Leader) setLeading(leading bool) {
+	l.leading.Store(leading)
+	select {
+	case l.changed <- struct{}{}:
+	default:
+	}
+}
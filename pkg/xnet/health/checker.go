@@ -0,0 +1,177 @@
+package health
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+	"github.com/flomesh-io/xnet/pkg/xnet/util"
+)
+
+type status struct {
+	healthy   bool
+	successes int
+	failures  int
+}
+
+// checkWorkers bounds the number of concurrent probes
+const checkWorkers = 32
+
+// Checker probes the endpoints of e4lb nat entries, and tracks their health by rise and fall thresholds.
+// The nat entries are left to the e4lb controller, which is notified through Changed
+// and reads the health of endpoints through Healthy.
+type Checker struct {
+	cfg        Config
+	httpClient *http.Client
+
+	mu       sync.RWMutex
+	statuses map[Target]*status
+	changed  chan struct{}
+}
+
+// NewChecker creates a health checker of nat endpoints
+func NewChecker(cfg Config) *Checker {
+	return &Checker{
+		cfg: cfg,
+		httpClient: &http.Client{
+			Timeout: cfg.Timeout,
+			// every probe opens its own connection, a pooled one proves nothing
+			Transport: &http.Transport{DisableKeepAlives: true},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		statuses: make(map[Target]*status),
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+// Changed is notified when the health of an endpoint flips
+func (c *Checker) Changed() <-chan struct{} {
+	return c.changed
+}
+
+// Healthy returns whether the target is healthy, targets not probed yet are healthy
+func (c *Checker) Healthy(target Target) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if st, exists := c.statuses[target]; exists {
+		return st.healthy
+	}
+	return true
+}
+
+// Run probes the endpoints every interval until stop is closed
+func (c *Checker) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.check()
+		}
+	}
+}
+
+func (c *Checker) check() {
+	natEntries, err := maps.GetNatEntries()
+	if err != nil {
+		log.Error().Err(err).Msg("fail to list nat entries")
+		return
+	}
+
+	targets := make(map[Target]bool)
+	for natKey, natVal := range natEntries {
+		if natKey.Sys != uint32(maps.SysE4lb) {
+			continue
+		}
+		for idx := 0; idx < int(natVal.EpCnt) && idx < len(natVal.Eps); idx++ {
+			ep := &natVal.Eps[idx]
+			targets[epTarget(&natKey, ep.Raddr, ep.Rport)] = true
+		}
+	}
+
+	probes := make(chan Target)
+	var wg sync.WaitGroup
+	for n := 0; n < checkWorkers && n < len(targets); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range probes {
+				c.record(target, c.probe(target))
+			}
+		}()
+	}
+	for target := range targets {
+		if maps.L4Proto(target.Proto) == maps.IPPROTO_UDP && !c.cfg.UDP {
+			continue
+		}
+		probes <- target
+	}
+	close(probes)
+	wg.Wait()
+
+	c.mu.Lock()
+	for target := range c.statuses {
+		if !targets[target] {
+			delete(c.statuses, target)
+		}
+	}
+	c.mu.Unlock()
+}
+
+func (c *Checker) probe(target Target) error {
+	switch maps.L4Proto(target.Proto) {
+	case maps.IPPROTO_TCP:
+		return c.probeTCP(target)
+	case maps.IPPROTO_UDP:
+		return c.probeUDP(target)
+	default:
+		return nil
+	}
+}
+
+func (c *Checker) record(target Target, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, exists := c.statuses[target]
+	if !exists {
+		st = &status{healthy: true}
+		c.statuses[target] = st
+	}
+	if err == nil {
+		st.failures = 0
+		if st.successes++; !st.healthy && st.successes >= c.cfg.Rise {
+			st.healthy = true
+			log.Info().Msgf("nat ep %s is healthy", target)
+			c.notify()
+		}
+	} else {
+		st.successes = 0
+		if st.failures++; st.healthy && st.failures >= c.cfg.Fall {
+			st.healthy = false
+			log.Warn().Err(err).Msgf("nat ep %s is unhealthy", target)
+			c.notify()
+		}
+	}
+}
+
+func (c *Checker) notify() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+func epTarget(natKey *maps.NatKey, raddr [4]uint32, rport uint16) Target {
+	target := Target{Proto: natKey.Proto, Port: util.NetToHostShort(rport)}
+	if natKey.V6 == 1 {
+		target.Addr = util.Int4ToIPv6(raddr).String()
+	} else {
+		target.Addr = util.IntToIPv4(raddr[0]).String()
+	}
+	return target
+}
@@ -0,0 +1,55 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// probeTCP requests the http path if configured, or just connects to the target
+func (c *Checker) probeTCP(target Target) error {
+	if len(c.cfg.HTTPPath) == 0 {
+		conn, err := net.DialTimeout("tcp", target.String(), c.cfg.Timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	resp, err := c.httpClient.Get(fmt.Sprintf("http://%s%s", target, c.cfg.HTTPPath))
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("http status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// probeUDP sends an empty datagram to the target, udp has no handshake,
+// so the target is down only if the port is reported unreachable.
+func (c *Checker) probeUDP(target Target) error {
+	conn, err := net.DialTimeout("udp", target.String(), c.cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(c.cfg.Timeout)); err != nil {
+		return err
+	}
+	if _, err = conn.Write([]byte{}); err != nil {
+		return err
+	}
+	buf := make([]byte, 1)
+	if _, err = conn.Read(buf); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package health
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/flomesh-io/xnet/pkg/logger"
+)
+
+var (
+	log = logger.New("fsm-xnet-health")
+)
+
+// Config is the health check config of nat endpoints
+type Config struct {
+	// Interval is the period of probes, health check is disabled if zero
+	Interval time.Duration
+	// Timeout is the timeout of a probe
+	Timeout time.Duration
+	// Rise is the number of consecutive successful probes to mark an endpoint healthy
+	Rise int
+	// Fall is the number of consecutive failed probes to mark an endpoint unhealthy
+	Fall int
+	// HTTPPath is requested on tcp endpoints after connected if not empty, a status below 400 is successful
+	HTTPPath string
+	// UDP enables probes of udp endpoints, which fail on icmp port unreachable only
+	UDP bool
+}
+
+// Enabled returns whether health check is enabled
+func (c Config) Enabled() bool {
+	return c.Interval > 0
+}
+
+// Validate checks the health check config
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.Timeout <= 0 || c.Timeout > c.Interval {
+		return fmt.Errorf("health check timeout must be positive and no longer than interval: %s", c.Timeout)
+	}
+	if c.Rise < 1 || c.Fall < 1 {
+		return fmt.Errorf("health check rise and fall must be positive: %d, %d", c.Rise, c.Fall)
+	}
+	return nil
+}
+
+// Target is a probed endpoint
+type Target struct {
+	Proto uint8
+	Addr  string
+	Port  uint16
+}
+
+// NewTarget returns the target of an endpoint, the port is in host byte order
+func NewTarget(proto uint8, addr net.IP, port uint16) Target {
+	return Target{Proto: proto, Addr: addr.String(), Port: port}
+}
+
+func (t Target) String() string {
+	return net.JoinHostPort(t.Addr, strconv.Itoa(int(t.Port)))
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf"
+	"github.com/flomesh-io/xnet/pkg/xnet/bpf/maps"
+)
+
+var (
+	datapathPktsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "datapath", "packets_total"),
+		"number of packets counted by the datapath",
+		[]string{"sys", "reason"}, nil)
+
+	datapathBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "datapath", "bytes_total"),
+		"number of bytes counted by the datapath",
+		[]string{"sys", "reason"}, nil)
+
+	mapEntriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bpf_map", "entries"),
+		"number of entries in the bpf map",
+		[]string{"map"}, nil)
+
+	mapMaxEntriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bpf_map", "max_entries"),
+		"capacity of the bpf map",
+		[]string{"map"}, nil)
+
+	natEndpointsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "nat", "endpoints"),
+		"number of endpoints of the nat entry, port_hi is 0 but for port ranges",
+		[]string{"sys", "addr", "port", "port_hi", "proto", "tc_dir"}, nil)
+)
+
+// occupancyInterval bounds how often the map occupancies are counted, walking the flow maps
+// is too expensive to do on every scrape.
+const occupancyInterval = 30 * time.Second
+
+type mapOccupancy struct {
+	entries    int
+	maxEntries uint32
+}
+
+// datapathCollector reads the pinned bpf maps on every scrape,
+// except the map occupancies which are cached for occupancyInterval.
+type datapathCollector struct {
+	mu          sync.Mutex
+	occupancies map[string]mapOccupancy
+	countedAt   time.Time
+}
+
+func newDatapathCollector() prometheus.Collector {
+	return &datapathCollector{}
+}
+
+func (c *datapathCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- datapathPktsDesc
+	ch <- datapathBytesDesc
+	ch <- mapEntriesDesc
+	ch <- mapMaxEntriesDesc
+	ch <- natEndpointsDesc
+}
+
+func (c *datapathCollector) Collect(ch chan<- prometheus.Metric) {
+	if stats, err := maps.GetStats(); err == nil {
+		for statKey, statVal := range stats {
+			sysName := maps.SysName(maps.SysID(statKey.Sys))
+			reason := maps.StatReason(statKey.Reason).String()
+			ch <- prometheus.MustNewConstMetric(datapathPktsDesc, prometheus.CounterValue, float64(statVal.Pkts), sysName, reason)
+			ch <- prometheus.MustNewConstMetric(datapathBytesDesc, prometheus.CounterValue, float64(statVal.Bytes), sysName, reason)
+		}
+	} else {
+		log.Debug().Err(err).Msg("fail to collect datapath stats")
+	}
+
+	for mapName, occupancy := range c.getOccupancies() {
+		ch <- prometheus.MustNewConstMetric(mapEntriesDesc, prometheus.GaugeValue, float64(occupancy.entries), mapName)
+		ch <- prometheus.MustNewConstMetric(mapMaxEntriesDesc, prometheus.GaugeValue, float64(occupancy.maxEntries), mapName)
+	}
+
+	if natEntries, err := maps.GetNatEntries(); err == nil {
+		for natKey, natVal := range natEntries {
+			ch <- prometheus.MustNewConstMetric(natEndpointsDesc, prometheus.GaugeValue, float64(natVal.EpCnt),
+				maps.SysName(maps.SysID(natKey.Sys)),
+				maps.IPName(natKey.Daddr),
+				strconv.Itoa(int(maps.PortName(natKey.Dport))),
+				strconv.Itoa(int(maps.PortName(natKey.DportHi))),
+				maps.ProtoName(natKey.Proto),
+				maps.TcDirName(natKey.TcDir))
+		}
+	} else {
+		log.Debug().Err(err).Msg("fail to collect nat entries")
+	}
+}
+
+func (c *datapathCollector) getOccupancies() map[string]mapOccupancy {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.occupancies != nil && time.Since(c.countedAt) < occupancyInterval {
+		return c.occupancies
+	}
+
+	occupancies := make(map[string]mapOccupancy, len(maps.MapNames))
+	for _, mapName := range maps.MapNames {
+		if mapName == bpf.FSM_MAP_NAME_TRACE_EVT {
+			continue
+		}
+		entries, maxEntries, err := maps.GetMapOccupancy(mapName)
+		if err != nil {
+			log.Debug().Err(err).Msgf("fail to collect occupancy of %s", mapName)
+			continue
+		}
+		occupancies[mapName] = mapOccupancy{entries: entries, maxEntries: maxEntries}
+	}
+	c.occupancies = occupancies
+	c.countedAt = time.Now()
+	return occupancies
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/flomesh-io/xnet/pkg/logger"
+)
+
+const (
+	namespace = `xnet`
+)
+
+var (
+	log = logger.New("fsm-xnet-metrics")
+
+	registry = prometheus.NewRegistry()
+)
+
+var (
+	// PodsAttached is the number of monitored pods with bpf prog attached
+	PodsAttached = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "pods",
+		Name:      "attached",
+		Help:      "number of monitored pods attached with bpf prog",
+	})
+
+	// PodsAttachFailed is the number of monitored pods failed to attach bpf prog
+	PodsAttachFailed = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "pods",
+		Name:      "attach_failed",
+		Help:      "number of monitored pods failed to attach bpf prog",
+	})
+
+	// FlowsFlushed counts the idle flows flushed by the cron jobs
+	FlowsFlushed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "flows",
+		Name:      "flushed_total",
+		Help:      "number of idle flows flushed",
+	}, []string{"sys", "proto"})
+
+	// PolicyReconcileDuration observes the duration of mesh policies reconcile
+	PolicyReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "policy",
+		Name:      "reconcile_duration_seconds",
+		Help:      "duration of mesh policies reconcile",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"policy"})
+)
+
+func init() {
+	registry.MustRegister(
+		PodsAttached,
+		PodsAttachFailed,
+		FlowsFlushed,
+		PolicyReconcileDuration,
+		newDatapathCollector(),
+	)
+}
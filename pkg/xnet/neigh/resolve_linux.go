@@ -0,0 +1,96 @@
+package neigh
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/flomesh-io/xnet/pkg/xnet/util"
+)
+
+// Resolve resolves the egress interface and the next-hop mac of an endpoint addr,
+// by the routing table and the neighbour table, falling back to arping or ndping.
+func Resolve(addr net.IP) (*Hop, error) {
+	routes, err := netlink.RouteGet(addr)
+	if err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("no route to %s", addr)
+	}
+	route := routes[0]
+	if route.Type == unix.RTN_LOCAL {
+		return nil, fmt.Errorf("local endpoint %s is not supported", addr)
+	}
+
+	iface, err := net.InterfaceByIndex(route.LinkIndex)
+	if err != nil {
+		return nil, err
+	}
+	hop := &Hop{
+		NextHop: addr,
+		Ofi:     uint32(route.LinkIndex),
+		Omac:    iface.HardwareAddr,
+	}
+	if route.Gw != nil {
+		hop.NextHop = route.Gw
+	}
+
+	family := netlink.FAMILY_V4
+	if hop.NextHop.To4() == nil {
+		family = netlink.FAMILY_V6
+	}
+	if neighs, neighErr := netlink.NeighList(route.LinkIndex, family); neighErr == nil {
+		for _, neigh := range neighs {
+			if !neigh.IP.Equal(hop.NextHop) || len(neigh.HardwareAddr) == 0 {
+				continue
+			}
+			if neigh.State&(netlink.NUD_FAILED|netlink.NUD_INCOMPLETE) != 0 {
+				continue
+			}
+			hop.Rmac = neigh.HardwareAddr
+			return hop, nil
+		}
+	}
+
+	if family == netlink.FAMILY_V4 {
+		hop.Rmac, err = util.ARPing(route.Src, hop.NextHop, *iface)
+	} else {
+		hop.Rmac, err = util.NDPing(route.Src, hop.NextHop, *iface)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return hop, nil
+}
+
+// watch calls onChange with each neighbour added, changed or deleted, until stop is closed.
+// The mac of a deleted or unresolved neighbour is nil.
+func watch(stop <-chan struct{}, onChange func(net.IP, net.HardwareAddr)) error {
+	updates := make(chan netlink.NeighUpdate)
+	done := make(chan struct{})
+	defer close(done)
+	if err := netlink.NeighSubscribe(updates, done); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stop:
+			return nil
+		case update, ok := <-updates:
+			if !ok {
+				return fmt.Errorf("neighbour subscription closed")
+			}
+			if update.IP == nil {
+				continue
+			}
+			if update.Type == unix.RTM_DELNEIGH || update.State&(netlink.NUD_FAILED|netlink.NUD_INCOMPLETE) != 0 {
+				onChange(update.IP, nil)
+			} else {
+				onChange(update.IP, update.HardwareAddr)
+			}
+		}
+	}
+}
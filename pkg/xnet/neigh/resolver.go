@@ -0,0 +1,96 @@
+package neigh
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+)
+
+const watchRetryPeriod = time.Second * 5
+
+// Resolver caches resolved hops of endpoint addrs,
+// the hops behind a changed neighbour are dropped and re-resolved on next lookup.
+type Resolver struct {
+	mu      sync.Mutex
+	hops    map[string]*Hop
+	changed chan struct{}
+}
+
+// NewResolver creates an endpoint resolver
+func NewResolver() *Resolver {
+	return &Resolver{
+		hops:    make(map[string]*Hop),
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// Resolve returns the cached hop of the addr, resolving it if missing
+func (r *Resolver) Resolve(addr net.IP) (*Hop, error) {
+	key := addr.String()
+	r.mu.Lock()
+	hop, exists := r.hops[key]
+	r.mu.Unlock()
+	if exists {
+		return hop, nil
+	}
+
+	hop, err := Resolve(addr)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.hops[key] = hop
+	r.mu.Unlock()
+	return hop, nil
+}
+
+// Changed notifies that cached hops were dropped and endpoints need to be resolved again
+func (r *Resolver) Changed() <-chan struct{} {
+	return r.changed
+}
+
+// Watch drops the hops behind neighbours whose mac changed or which are gone, until stop is closed
+func (r *Resolver) Watch(stop <-chan struct{}) {
+	for {
+		if err := watch(stop, r.forget); err != nil {
+			log.Error().Err(err).Msg("fail to watch neighbours")
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(watchRetryPeriod):
+			// updates may be missed while resubscribing
+			r.reset()
+		}
+	}
+}
+
+func (r *Resolver) forget(nextHop net.IP, mac net.HardwareAddr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	forgotten := false
+	for key, hop := range r.hops {
+		if hop.NextHop.Equal(nextHop) && !bytes.Equal(hop.Rmac, mac) {
+			delete(r.hops, key)
+			forgotten = true
+		}
+	}
+	if forgotten {
+		r.notify()
+	}
+}
+
+func (r *Resolver) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hops = make(map[string]*Hop)
+	r.notify()
+}
+
+func (r *Resolver) notify() {
+	select {
+	case r.changed <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,25 @@
+package neigh
+
+import (
+	"net"
+
+	"github.com/flomesh-io/xnet/pkg/logger"
+)
+
+var (
+	log = logger.New("fsm-xnet-neigh")
+)
+
+// Hop is the resolved egress of an endpoint addr, as filled into nat_ep_t
+type Hop struct {
+	// NextHop is the gateway of the endpoint, or the endpoint itself if it is on link
+	NextHop net.IP
+	// Ofi is the index of the egress interface
+	Ofi uint32
+	// Oflags is the redirect flags, 0 is BPF_F_EGRESS
+	Oflags uint32
+	// Rmac is the mac of the next hop
+	Rmac net.HardwareAddr
+	// Omac is the mac of the egress interface
+	Omac net.HardwareAddr
+}
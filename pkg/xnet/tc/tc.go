@@ -143,6 +143,21 @@ func deleteBPFFilter(rtnl *tc.Tc, ifIndex, parent, progFD uint32) error {
 	return rtnl.Filter().Delete(&filter)
 }
 
+func replaceBPFFilter(rtnl *tc.Tc, filter *tc.Object, progFD uint32) error {
+	replace := tc.Object{
+		Msg: filter.Msg,
+		Attribute: tc.Attribute{
+			Kind: TC_KIND_BPF,
+			BPF: &tc.Bpf{
+				FD:    uint32Ptr(progFD),
+				Name:  stringPtr(fmt.Sprintf("%s_%d", TC_BPF_FILTER_PREFIX, progFD)),
+				Flags: uint32Ptr(0x1),
+			},
+		},
+	}
+	return rtnl.Filter().Replace(&replace)
+}
+
 func ShowBPFProg(dev string) error {
 	iface, ifaceErr := net.InterfaceByName(dev)
 	if ifaceErr != nil {
@@ -328,3 +343,108 @@ func DetachBPFProg(sysId maps.SysID, dev string, ingress, egress bool) error {
 
 	return nil
 }
+
+// ReplaceBPFProg swaps the progs of the existing tc filters with the currently
+// pinned ones in place, devices without filters are left untouched.
+func ReplaceBPFProg(sysId maps.SysID, dev string, ingress, egress bool) error {
+	var ingressProgName, egressProgName string
+
+	switch sysId {
+	case maps.SysNoop:
+		ingressProgName = bpf.FSM_NOOP_INGRESS_PROG_NAME
+		egressProgName = bpf.FSM_NOOP_EGRESS_PROG_NAME
+	case maps.SysMesh:
+		ingressProgName = bpf.FSM_MESH_INGRESS_PROG_NAME
+		egressProgName = bpf.FSM_MESH_EGRESS_PROG_NAME
+	case maps.SysE4lb:
+		ingressProgName = bpf.FSM_E4LB_INGRESS_PROG_NAME
+		egressProgName = bpf.FSM_E4LB_EGRESS_PROG_NAME
+	default:
+		return fmt.Errorf("invalid fsm sys: %d", sysId)
+	}
+
+	iface, ifaceErr := net.InterfaceByName(dev)
+	if ifaceErr != nil {
+		return ifaceErr
+	}
+
+	rtnl, rtnlErr := tc.Open(&tc.Config{})
+	if rtnlErr != nil {
+		return rtnlErr
+	}
+
+	defer func() {
+		if err := rtnl.Close(); err != nil {
+			log.Error().Msgf("could not close rtnetlink socket: %v\n", err)
+		}
+	}()
+
+	if qdisc, _ := GetBPFQdisc(rtnl, uint32(iface.Index)); qdisc == nil {
+		return nil
+	}
+
+	if ingress {
+		if filter, _ := GetBPFFilter(rtnl, uint32(iface.Index), HandleIngress); filter != nil {
+			if ingressProgFD, ingressProgFDErr := getBPFObjFD(ingressProgName); ingressProgFDErr != nil {
+				return ingressProgFDErr
+			} else if err := replaceBPFFilter(rtnl, filter, uint32(ingressProgFD)); err != nil {
+				log.Error().Msgf("replace tc ingress filter error: %v", err)
+				return err
+			} else {
+				log.Debug().Msgf("tc ingress filter replace success: %s", iface.Name)
+			}
+		}
+	}
+
+	if egress {
+		if filter, _ := GetBPFFilter(rtnl, uint32(iface.Index), HandleEgress); filter != nil {
+			if egressProgFD, egressProgFDErr := getBPFObjFD(egressProgName); egressProgFDErr != nil {
+				return egressProgFDErr
+			} else if err := replaceBPFFilter(rtnl, filter, uint32(egressProgFD)); err != nil {
+				log.Error().Msgf("replace tc egress filter error: %v", err)
+				return err
+			} else {
+				log.Debug().Msgf("tc egress filter replace success: %s", iface.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// HasBPFProg returns whether the tc filters of the requested directions are attached to the device.
+func HasBPFProg(dev string, ingress, egress bool) (bool, error) {
+	iface, ifaceErr := net.InterfaceByName(dev)
+	if ifaceErr != nil {
+		return false, ifaceErr
+	}
+
+	rtnl, rtnlErr := tc.Open(&tc.Config{})
+	if rtnlErr != nil {
+		return false, rtnlErr
+	}
+
+	defer func() {
+		if err := rtnl.Close(); err != nil {
+			log.Error().Msgf("could not close rtnetlink socket: %v\n", err)
+		}
+	}()
+
+	if ingress {
+		if filter, err := GetBPFFilter(rtnl, uint32(iface.Index), HandleIngress); err != nil {
+			return false, err
+		} else if filter == nil {
+			return false, nil
+		}
+	}
+
+	if egress {
+		if filter, err := GetBPFFilter(rtnl, uint32(iface.Index), HandleEgress); err != nil {
+			return false, err
+		} else if filter == nil {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
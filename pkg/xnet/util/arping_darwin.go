@@ -6,3 +6,8 @@ import "net"
 func ARPing(srcIP, dstIP net.IP, iface net.Interface) (net.HardwareAddr, error) {
 	panic("Unsupported!")
 }
+
+// NDPing sends a neighbor solicitation over interface 'iface' to 'dstIP'
+func NDPing(srcIP, dstIP net.IP, iface net.Interface) (net.HardwareAddr, error) {
+	panic("Unsupported!")
+}
@@ -0,0 +1,122 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	ndpHopLimit = 255
+
+	ndpOptSourceLinkLayerAddr = 1
+	ndpOptTargetLinkLayerAddr = 2
+)
+
+// solicitedNodeMulticast returns the solicited-node multicast addr of the ipv6 addr
+func solicitedNodeMulticast(ip net.IP) net.IP {
+	addr := net.ParseIP("ff02::1:ff00:0")
+	copy(addr[13:], ip.To16()[13:])
+	return addr
+}
+
+func newNeighborSolicitation(srcMac net.HardwareAddr, dstIP net.IP) ([]byte, error) {
+	// reserved(4) + target(16) + source link-layer addr option(8)
+	body := make([]byte, 4, 28)
+	body = append(body, dstIP.To16()...)
+	body = append(body, ndpOptSourceLinkLayerAddr, 1)
+	body = append(body, srcMac...)
+
+	msg := icmp.Message{
+		Type: ipv6.ICMPTypeNeighborSolicitation,
+		Body: &icmp.RawBody{Data: body},
+	}
+	// the checksum is filled by the kernel
+	return msg.Marshal(nil)
+}
+
+// parseNeighborAdvertisement returns the target addr and the target link-layer addr of the advertisement
+func parseNeighborAdvertisement(bs []byte) (net.IP, net.HardwareAddr) {
+	msg, err := icmp.ParseMessage(ipv6.ICMPTypeNeighborAdvertisement.Protocol(), bs)
+	if err != nil || msg.Type != ipv6.ICMPTypeNeighborAdvertisement {
+		return nil, nil
+	}
+	body, ok := msg.Body.(*icmp.RawBody)
+	if !ok || len(body.Data) < 20 {
+		return nil, nil
+	}
+	target := net.IP(body.Data[4:20])
+	for opts := body.Data[20:]; len(opts) >= 8; {
+		optLen := int(opts[1]) * 8
+		if optLen == 0 || optLen > len(opts) {
+			break
+		}
+		if opts[0] == ndpOptTargetLinkLayerAddr {
+			return target, net.HardwareAddr(opts[2:8])
+		}
+		opts = opts[optLen:]
+	}
+	return target, nil
+}
+
+// NDPing sends a neighbor solicitation over interface 'iface' to 'dstIP'
+func NDPing(srcIP, dstIP net.IP, iface net.Interface) (net.HardwareAddr, error) {
+	request, err := newNeighborSolicitation(iface.HardwareAddr, dstIP)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	pc := c.IPv6PacketConn()
+	var filter ipv6.ICMPFilter
+	filter.SetAll(true)
+	filter.Accept(ipv6.ICMPTypeNeighborAdvertisement)
+	if err = pc.SetICMPFilter(&filter); err != nil {
+		return nil, err
+	}
+	if err = pc.SetControlMessage(ipv6.FlagInterface, true); err != nil {
+		return nil, err
+	}
+	if err = pc.SetMulticastHopLimit(ndpHopLimit); err != nil {
+		return nil, err
+	}
+
+	cm := &ipv6.ControlMessage{HopLimit: ndpHopLimit, IfIndex: iface.Index}
+	if srcIP != nil && srcIP.To4() == nil {
+		cm.Src = srcIP
+	}
+	dst := &net.IPAddr{IP: solicitedNodeMulticast(dstIP), Zone: iface.Name}
+	if _, err = pc.WriteTo(request, cm, dst); err != nil {
+		return nil, err
+	}
+
+	if err = pc.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	buffer := make([]byte, 128)
+	for {
+		n, rcm, _, readErr := pc.ReadFrom(buffer)
+		if readErr != nil {
+			var netErr net.Error
+			if errors.As(readErr, &netErr) && netErr.Timeout() {
+				return nil, errors.New("ndping timeout")
+			}
+			return nil, readErr
+		}
+		if rcm != nil && rcm.IfIndex != iface.Index {
+			continue
+		}
+		if target, mac := parseNeighborAdvertisement(buffer[:n]); mac != nil && bytes.Equal(target, dstIP.To16()) {
+			return mac, nil
+		}
+	}
+}